@@ -3,6 +3,7 @@
 package arena
 
 import (
+	"reflect"
 	"unsafe"
 )
 
@@ -35,11 +36,76 @@ type Arena interface {
 	Peak() int
 }
 
+// Freer is an optional interface implemented by arenas that can reclaim
+// individual allocations before the arena is reset.
+type Freer interface {
+	// Free returns the memory at ptr to the arena for reuse. ptr must have
+	// been returned by Alloc on the same arena with the same size and
+	// alignment, and must not be used afterwards.
+	Free(ptr unsafe.Pointer, size, alignment uintptr)
+}
+
+// Rewinder is an optional interface implemented by arenas that can roll back
+// to an earlier state without a full Reset, e.g. to discard speculative work.
+type Rewinder interface {
+	// Mark returns a checkpoint of the arena's current state.
+	Mark() Checkpoint
+
+	// Rewind releases all memory allocated since cp was taken by Mark. Any
+	// pointer returned by Alloc after that becomes invalid, while earlier
+	// allocations stay intact. Checkpoints nest like a stack: rewinding to cp
+	// invalidates all checkpoints taken after it. Rewind panics if cp was
+	// taken before the last Reset or Release, or was already rewound past.
+	Rewind(cp Checkpoint)
+}
+
+// Trimmer is an optional interface implemented by arenas that can give back
+// surplus capacity, e.g. before an oversized arena is pooled for reuse.
+type Trimmer interface {
+	// Trim drops buffers until Cap is at most size bytes, replacing them
+	// with a single buffer of size bytes if none fits. It must only be
+	// called on an arena without live allocations, e.g. right after Reset.
+	Trim(size int)
+}
+
+// Checkpoint is a position in an arena's allocation history, see Rewinder.
+type Checkpoint struct {
+	cursor     int
+	offset     uintptr
+	totalAlloc uintptr
+	gen        uint64
+	depth      int    // number of valid checkpoints when it was taken
+	seq        uint64 // identifies the checkpoint among those of its arena
+	slabs      []slabCheckpoint
+}
+
+type slabCheckpoint struct {
+	typ  reflect.Type
+	mark slabMark
+}
+
+func (cp Checkpoint) slabMark(typ reflect.Type) (slabMark, bool) {
+	for _, s := range cp.slabs {
+		if s.typ == typ {
+			return s.mark, true
+		}
+	}
+	return slabMark{}, false
+}
+
 // Allocate allocates memory for a value of type T using the provided Arena.
 // If the arena is non-nil, it returns a  *T pointer with memory allocated from the arena.
 // If passed arena is nil, it allocates memory using Go's built-in new function.
+// Types containing pointers are served from GC-scanned memory when the arena
+// supports it and from the heap otherwise.
 func Allocate[T any](a Arena) *T {
 	if a != nil {
+		if ptr, ok := allocPointers[T](a, 1); ok {
+			if ptr != nil {
+				return ptr
+			}
+			return new(T)
+		}
 		var x T
 		if ptr := a.Alloc(unsafe.Sizeof(x), unsafe.Alignof(x)); ptr != nil {
 			return (*T)(ptr)
@@ -47,3 +113,19 @@ func Allocate[T any](a Arena) *T {
 	}
 	return new(T)
 }
+
+// Free returns the memory of a value allocated with Allocate to the arena.
+// It is a no-op if the arena doesn't implement Freer, and for values that
+// weren't served from arena buffers, such as types containing pointers.
+// ptr must not be used after calling Free.
+func Free[T any](a Arena, ptr *T) {
+	if a == nil || ptr == nil {
+		return
+	}
+	f, ok := a.(Freer)
+	if !ok || typeHasPointers(reflect.TypeFor[T]()) {
+		return
+	}
+	var x T
+	f.Free(unsafe.Pointer(ptr), unsafe.Sizeof(x), unsafe.Alignof(x))
+}
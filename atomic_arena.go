@@ -0,0 +1,226 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package arena
+
+import (
+	"sync"
+	"sync/atomic"
+	"unsafe"
+)
+
+// atomicArena is a monotonic arena whose fast path bumps the offset of the
+// current buffer with compare-and-swap, so concurrent goroutines allocate
+// without taking a lock. The mutex is only taken to switch to another buffer.
+type atomicArena struct {
+	current atomic.Pointer[atomicBuffer] // buffer the fast path allocates from; nil until the first Alloc
+
+	mu            sync.Mutex // guards buffers and cursor
+	buffers       []*atomicBuffer
+	cursor        int // index of current in buffers
+	minBufferSize uintptr
+
+	peak atomic.Int64
+	gen  atomic.Uint64
+}
+
+type atomicBuffer struct {
+	ptr    unsafe.Pointer // allocated when the buffer first becomes current
+	size   uintptr
+	offset atomic.Uintptr
+}
+
+// NewAtomicArena returns a monotonic arena that is safe to be accessed
+// concurrently from multiple goroutines, for use where many goroutines
+// allocate at the same time: allocations that fit into the current buffer are
+// lock-free, and only appending a new buffer takes a lock.
+//
+// It supports WithMinBufferSize and WithInitialBufferCount, and panics if
+// passed WithPoisonOnReset or WithPointerPolicy(PointerPolicyPanic).
+//
+// Unlike NewConcurrentArena(NewMonotonicArena(...)), Reset and Release must
+// not be called concurrently with Alloc, and types containing pointers are
+// allocated on the heap instead of GC-scanned memory.
+func NewAtomicArena(opts ...MonotonicArenaOption) Arena {
+	m := NewMonotonicArena(opts...).(*monotonicArena)
+	m.requireBufferOptions("NewAtomicArena")
+	a := &atomicArena{
+		minBufferSize: m.minBufferSize,
+	}
+	for _, b := range m.buffers {
+		a.buffers = append(a.buffers, &atomicBuffer{size: b.size})
+	}
+	return a
+}
+
+// alloc reserves size bytes aligned to alignment. It never leaves the buffer
+// in a partially updated state: either the CAS publishes the whole
+// reservation, or the buffer is unchanged.
+func (b *atomicBuffer) alloc(size, alignment uintptr) (unsafe.Pointer, bool) {
+	for {
+		old := b.offset.Load()
+		offset := old
+		if alignment > 1 {
+			if rem := (uintptr(b.ptr) + offset) % alignment; rem != 0 {
+				offset += alignment - rem
+			}
+		}
+		end := offset + size
+		if end < offset || end > b.size {
+			return nil, false
+		}
+		if b.offset.CompareAndSwap(old, end) {
+			return unsafe.Add(b.ptr, offset), true
+		}
+	}
+}
+
+// Alloc satisfies the Arena interface.
+func (a *atomicArena) Alloc(size, alignment uintptr) unsafe.Pointer {
+	if size == 0 {
+		return nil
+	}
+	if b := a.current.Load(); b != nil {
+		if ptr, ok := b.alloc(size, alignment); ok {
+			return ptr
+		}
+	}
+	return a.allocSlow(size, alignment)
+}
+
+// allocSlow moves on to the next buffer with enough space, appending a new
+// one if none is left.
+func (a *atomicArena) allocSlow(size, alignment uintptr) unsafe.Pointer {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	start := a.cursor
+	if cur := a.current.Load(); cur != nil {
+		// Another goroutine may have switched buffers while we were
+		// waiting for the lock.
+		if ptr, ok := cur.alloc(size, alignment); ok {
+			return ptr
+		}
+		start++
+	}
+	for i := start; i < len(a.buffers); i++ {
+		b := a.buffers[i]
+		if b.ptr == nil {
+			if b.size > uintptr(maxInt) {
+				continue
+			}
+			b.ptr = unsafe.Pointer(unsafe.SliceData(make([]byte, b.size)))
+		}
+		if ptr, ok := b.alloc(size, alignment); ok {
+			a.cursor = i
+			a.current.Store(b)
+			return ptr
+		}
+	}
+
+	// Same sizing as monotonicArena.Alloc.
+	newBufferSize := size
+	if alignment > 1 {
+		newBufferSize += alignment - 1
+		if newBufferSize < size {
+			return nil
+		}
+	}
+	newBufferSize = max(newBufferSize, a.minBufferSize)
+	if newBufferSize > uintptr(maxInt) {
+		return nil
+	}
+	b := &atomicBuffer{
+		ptr:  unsafe.Pointer(unsafe.SliceData(make([]byte, newBufferSize))),
+		size: newBufferSize,
+	}
+	ptr, _ := b.alloc(size, alignment)
+	a.buffers = append(a.buffers, b)
+	a.cursor = len(a.buffers) - 1
+	a.current.Store(b)
+	return ptr
+}
+
+// updatePeak records the current length as peak if it's a new maximum.
+// Between two Resets the length only grows, so sampling it whenever Peak is
+// read or the arena is reset is enough to track the exact maximum.
+func (a *atomicArena) updatePeak() int64 {
+	n := int64(a.Len())
+	for {
+		peak := a.peak.Load()
+		if n <= peak {
+			return peak
+		}
+		if a.peak.CompareAndSwap(peak, n) {
+			return n
+		}
+	}
+}
+
+// Reset satisfies the Arena interface.
+func (a *atomicArena) Reset() {
+	a.updatePeak()
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for _, b := range a.buffers {
+		if n := b.offset.Load(); n > 0 {
+			clear(unsafe.Slice((*byte)(b.ptr), n))
+			b.offset.Store(0)
+		}
+	}
+	a.current.Store(nil)
+	a.cursor = 0
+	a.gen.Add(1)
+}
+
+// Release satisfies the Arena interface.
+func (a *atomicArena) Release() {
+	a.updatePeak()
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for _, b := range a.buffers {
+		b.ptr = nil
+		b.offset.Store(0)
+	}
+	a.current.Store(nil)
+	a.cursor = 0
+	a.gen.Add(1)
+}
+
+// Len returns the total number of bytes currently allocated in the arena.
+func (a *atomicArena) Len() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	var total uintptr
+	for _, b := range a.buffers {
+		total += b.offset.Load()
+	}
+	return int(total)
+}
+
+// Cap returns the total capacity (maximum bytes) that can be allocated in the arena.
+func (a *atomicArena) Cap() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	var total uintptr
+	for _, b := range a.buffers {
+		total += b.size
+	}
+	return int(total)
+}
+
+// Peak returns the peak number of bytes that have been allocated in the arena.
+// This value is not reset when Reset is called, allowing tracking of maximum usage.
+func (a *atomicArena) Peak() int {
+	return int(a.updatePeak())
+}
+
+// cyclePeak satisfies the cyclePeaker interface. The length only grows
+// between two Resets, so the current length is the peak of the cycle.
+func (a *atomicArena) cyclePeak() int {
+	return a.Len()
+}
+
+// generation satisfies the generational interface.
+func (a *atomicArena) generation() uint64 {
+	return a.gen.Load()
+}
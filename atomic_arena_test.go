@@ -0,0 +1,130 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package arena
+
+import (
+	"sync"
+	"testing"
+	"unsafe"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAtomicArenaLenCapPeak(t *testing.T) {
+	arena := NewAtomicArena(WithInitialBufferCount(2), WithMinBufferSize(256))
+	require.Equal(t, 512, arena.Cap())
+	require.Equal(t, 0, arena.Len())
+	require.Nil(t, arena.Alloc(0, 1))
+
+	arena.Alloc(100, 1)
+	arena.Alloc(100, 1)
+	require.Equal(t, 200, arena.Len())
+
+	// Moves on to the second initial buffer.
+	arena.Alloc(100, 1)
+	require.Equal(t, 300, arena.Len())
+	require.Equal(t, 512, arena.Cap())
+
+	// Appends a dedicated buffer.
+	arena.Alloc(1000, 1)
+	require.Equal(t, 1300, arena.Len())
+	require.Equal(t, 1512, arena.Cap())
+	require.Equal(t, 1300, arena.Peak())
+
+	arena.Reset()
+	require.Equal(t, 0, arena.Len())
+	require.Equal(t, 1512, arena.Cap())
+	require.Equal(t, 1300, arena.Peak())
+
+	arena.Release()
+	require.Equal(t, 0, arena.Len())
+	require.Equal(t, 1512, arena.Cap())
+	require.NotNil(t, arena.Alloc(10, 1))
+}
+
+func TestAtomicArenaAlignmentAndZeroing(t *testing.T) {
+	arena := NewAtomicArena(WithMinBufferSize(256))
+
+	arena.Alloc(1, 1)
+	ptr := arena.Alloc(64, 32)
+	require.Zero(t, uintptr(ptr)%32)
+	s := unsafe.Slice((*byte)(ptr), 64)
+	for i := range s {
+		s[i] = 0xff
+	}
+
+	arena.Reset()
+	arena.Alloc(1, 1)
+	ptr2 := arena.Alloc(64, 32)
+	require.Equal(t, ptr, ptr2)
+	require.Equal(t, make([]byte, 64), unsafe.Slice((*byte)(ptr2), 64))
+}
+
+func TestAtomicArenaOverflowGuard(t *testing.T) {
+	arena := NewAtomicArena()
+	arena.Alloc(1, 1)
+	require.Nil(t, arena.Alloc(^uintptr(0)-2, 8))
+	require.Nil(t, arena.Alloc(uintptr(maxInt)+1, 1))
+}
+
+func TestAtomicArenaConcurrentAllocationsDontOverlap(t *testing.T) {
+	arena := NewAtomicArena(WithMinBufferSize(1024))
+
+	const goroutines, allocs = 16, 500
+	var wg sync.WaitGroup
+	results := make([][]*int64, goroutines)
+	for g := range goroutines {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range allocs {
+				v := Allocate[int64](arena)
+				*v = int64(g*allocs + i)
+				results[g] = append(results[g], v)
+			}
+		}()
+	}
+	wg.Wait()
+
+	require.Equal(t, goroutines*allocs*8, arena.Len())
+	for g, vs := range results {
+		for i, v := range vs {
+			require.Equal(t, int64(g*allocs+i), *v)
+		}
+	}
+}
+
+func TestAtomicArenaRef(t *testing.T) {
+	arena := NewAtomicArena()
+
+	r := AllocateRef[int64](arena)
+	arena.Reset()
+	require.False(t, r.Valid())
+
+	// Types containing pointers go to the heap.
+	require.NotNil(t, Allocate[pointerStruct](arena))
+	require.Equal(t, 0, arena.Len())
+}
+
+func TestAtomicArenaRejectsUnsupportedOptions(t *testing.T) {
+	require.PanicsWithValue(t, "arena: NewAtomicArena doesn't support WithPoisonOnReset", func() {
+		NewAtomicArena(WithPoisonOnReset())
+	})
+	require.PanicsWithValue(t, "arena: NewAtomicArena doesn't support PointerPolicyPanic", func() {
+		NewAtomicArena(WithPointerPolicy(PointerPolicyPanic))
+	})
+}
+
+func BenchmarkAtomicArenaAlloc(b *testing.B) {
+	arena := NewAtomicArena(WithInitialBufferCount(1), WithMinBufferSize(1024*1024))
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ptr := arena.Alloc(100, 1)
+		_ = ptr
+	}
+}
+
+func BenchmarkAtomicArenaAllocParallel(b *testing.B) {
+	benchmarkParallelAlloc(b, NewAtomicArena(WithMinBufferSize(1024*1024)))
+}
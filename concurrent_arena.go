@@ -3,6 +3,7 @@
 package arena
 
 import (
+	"reflect"
 	"sync"
 	"unsafe"
 )
@@ -28,6 +29,46 @@ func (a *concurrentArena) Alloc(size, alignment uintptr) unsafe.Pointer {
 	return a.a.Alloc(size, alignment)
 }
 
+// allocScan satisfies the scanAllocator interface.
+func (a *concurrentArena) allocScan(typ reflect.Type, n int, newSlab func() scanSlab) unsafe.Pointer {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+	sa, ok := a.a.(scanAllocator)
+	if !ok {
+		return nil
+	}
+	return sa.allocScan(typ, n, newSlab)
+}
+
+// Free satisfies the Freer interface.
+// It is a no-op if the wrapped arena doesn't implement Freer.
+func (a *concurrentArena) Free(ptr unsafe.Pointer, size, alignment uintptr) {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+	if f, ok := a.a.(Freer); ok {
+		f.Free(ptr, size, alignment)
+	}
+}
+
+// Trim satisfies the Trimmer interface.
+// It is a no-op if the wrapped arena doesn't implement Trimmer.
+func (a *concurrentArena) Trim(size int) {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+	if t, ok := a.a.(Trimmer); ok {
+		t.Trim(size)
+	}
+}
+
+// prewarm satisfies the prewarmer interface.
+func (a *concurrentArena) prewarm() {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+	if pw, ok := a.a.(prewarmer); ok {
+		pw.prewarm()
+	}
+}
+
 // Reset satisfies the Arena interface.
 func (a *concurrentArena) Reset() {
 	a.mtx.Lock()
@@ -78,3 +119,24 @@ func (a *concurrentArena) Peak() int {
 	}
 	return a.a.Peak()
 }
+
+// cyclePeak satisfies the cyclePeaker interface.
+func (a *concurrentArena) cyclePeak() int {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+	if a.a == nil {
+		return 0
+	}
+	return peakOfCycle(a.a)
+}
+
+// generation satisfies the generational interface.
+func (a *concurrentArena) generation() uint64 {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+	g, ok := a.a.(generational)
+	if !ok {
+		return 0
+	}
+	return g.generation()
+}
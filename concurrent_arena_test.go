@@ -228,6 +228,10 @@ func BenchmarkConcurrentArenaAlloc(b *testing.B) {
 	}
 }
 
+func BenchmarkConcurrentArenaAllocParallel(b *testing.B) {
+	benchmarkParallelAlloc(b, NewConcurrentArena(NewMonotonicArena(WithMinBufferSize(1024*1024))))
+}
+
 func TestConcurrentArenaPeak(t *testing.T) {
 	// Create a concurrent arena wrapping a monotonic arena
 	baseArena := NewMonotonicArena(WithInitialBufferCount(1), WithMinBufferSize(1024))
@@ -505,3 +509,12 @@ func TestConcurrentArenaPeakOnAllocationFailureWrappingNil(t *testing.T) {
 	require.Equal(t, 0, arena.Len())
 	require.Equal(t, 0, arena.Peak()) // Peak should remain 0 for nil arena
 }
+
+func TestConcurrentArenaTrim(t *testing.T) {
+	arena := NewConcurrentArena(NewMonotonicArena(WithInitialBufferCount(2)))
+	arena.(Trimmer).Trim(minBufferSize)
+	require.Equal(t, minBufferSize, arena.Cap())
+
+	// A no-op for arenas not implementing Trimmer.
+	NewConcurrentArena(&mockArena{}).(Trimmer).Trim(0)
+}
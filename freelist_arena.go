@@ -0,0 +1,149 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package arena
+
+import (
+	"math/bits"
+	"unsafe"
+)
+
+// freeListMaxAlign is the largest alignment a free-list arena supports.
+// Blocks are aligned to their size class up to this value.
+const freeListMaxAlign = 64
+
+// freeListArena serves allocations from power-of-two size classes and keeps a
+// free list per class, so that memory passed to Free is handed out again by
+// later allocations of the same class. Blocks are carved from a monotonic
+// arena, which also provides the memory's lifetime: Reset and Release drop
+// all free lists along with the blocks.
+type freeListArena struct {
+	backing    *monotonicArena
+	free       [bits.UintSize]unsafe.Pointer // head of the free list per size class
+	totalAlloc uintptr                       // bytes in blocks that are handed out
+	peak       uintptr                       // peak of earlier cycles
+	curPeak    uintptr                       // peak since the last Reset or Release
+}
+
+// NewFreeListArena creates an arena that supports freeing individual
+// allocations through Free, FreeSlice or its Freer implementation.
+// It is meant for long-lived arenas, e.g. per subscription, whose memory
+// would otherwise only grow.
+//
+// Every allocation is rounded up to a power-of-two size class of at least a
+// pointer's size, and freed blocks are reused by later allocations of the same
+// class. Alignments must be powers of two of at most 64 bytes; Alloc returns
+// nil for anything else.
+//
+// The options configure the buffers blocks are carved from, see NewMonotonicArena.
+func NewFreeListArena(opts ...MonotonicArenaOption) Arena {
+	return &freeListArena{
+		backing: NewMonotonicArena(opts...).(*monotonicArena),
+	}
+}
+
+// sizeClass returns the index and block size of the class serving an
+// allocation of size bytes with the given alignment.
+func sizeClass(size, alignment uintptr) (int, uintptr, bool) {
+	if alignment > freeListMaxAlign || alignment&(alignment-1) != 0 {
+		return 0, 0, false
+	}
+	n := max(size, alignment, unsafe.Sizeof(unsafe.Pointer(nil)))
+	class := bits.Len(uint(n - 1))
+	if class >= bits.UintSize {
+		return 0, 0, false
+	}
+	return class, 1 << class, true
+}
+
+// Alloc satisfies the Arena interface.
+func (a *freeListArena) Alloc(size, alignment uintptr) unsafe.Pointer {
+	if size == 0 {
+		return nil
+	}
+	class, blockSize, ok := sizeClass(size, alignment)
+	if !ok {
+		return nil
+	}
+	ptr := a.free[class]
+	if ptr != nil {
+		a.free[class] = *(*unsafe.Pointer)(ptr)
+		// Freed blocks hold the free list link and whatever the previous
+		// owner left behind.
+		clear(unsafe.Slice((*byte)(ptr), size))
+	} else {
+		ptr = a.backing.Alloc(blockSize, min(blockSize, freeListMaxAlign))
+		if ptr == nil {
+			return nil
+		}
+	}
+	a.totalAlloc += blockSize
+	if a.totalAlloc > a.curPeak {
+		a.curPeak = a.totalAlloc
+	}
+	return ptr
+}
+
+// Free satisfies the Freer interface.
+func (a *freeListArena) Free(ptr unsafe.Pointer, size, alignment uintptr) {
+	if ptr == nil || size == 0 {
+		return
+	}
+	class, blockSize, ok := sizeClass(size, alignment)
+	if !ok {
+		return
+	}
+	// The link is stored in the block itself. Blocks are at least pointer
+	// sized and aligned, and the backing buffers keep them alive, so the
+	// link doesn't need to be visible to the garbage collector.
+	*(*unsafe.Pointer)(ptr) = a.free[class]
+	a.free[class] = ptr
+	a.totalAlloc -= blockSize
+}
+
+// Reset satisfies the Arena interface.
+func (a *freeListArena) Reset() {
+	a.backing.Reset()
+	a.free = [bits.UintSize]unsafe.Pointer{}
+	a.endCycle()
+}
+
+// Release satisfies the Arena interface.
+func (a *freeListArena) Release() {
+	a.backing.Release()
+	a.free = [bits.UintSize]unsafe.Pointer{}
+	a.endCycle()
+}
+
+// Len returns the total number of bytes currently allocated in the arena.
+// Freed blocks don't count towards it.
+func (a *freeListArena) Len() int {
+	return int(a.totalAlloc)
+}
+
+// Cap returns the total capacity (maximum bytes) that can be allocated in the arena.
+func (a *freeListArena) Cap() int {
+	return a.backing.Cap()
+}
+
+// Peak returns the peak number of bytes that have been allocated in the arena.
+// This value is not reset when Reset is called, allowing tracking of maximum usage.
+func (a *freeListArena) Peak() int {
+	return int(max(a.peak, a.curPeak))
+}
+
+// cyclePeak satisfies the cyclePeaker interface.
+func (a *freeListArena) cyclePeak() int {
+	return int(a.curPeak)
+}
+
+// endCycle is like monotonicArena.endCycle.
+func (a *freeListArena) endCycle() {
+	a.peak = max(a.peak, a.curPeak)
+	a.curPeak = 0
+	a.totalAlloc = 0
+}
+
+// generation satisfies the generational interface.
+func (a *freeListArena) generation() uint64 {
+	return a.backing.generation()
+}
@@ -0,0 +1,162 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package arena
+
+import (
+	"math/bits"
+	"testing"
+	"unsafe"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSizeClass(t *testing.T) {
+	// Blocks hold at least a pointer, to link them into the free list.
+	ptrSize := unsafe.Sizeof(unsafe.Pointer(nil))
+	minClass := bits.Len(uint(ptrSize - 1))
+	tests := []struct {
+		size, alignment uintptr
+		class           int
+		blockSize       uintptr
+		ok              bool
+	}{
+		{1, 1, minClass, ptrSize, true},
+		{4, 4, minClass, ptrSize, true},
+		{8, 8, 3, 8, true},
+		{9, 1, 4, 16, true},
+		{24, 8, 5, 32, true},
+		{8, 32, 5, 32, true},
+		{4096, 8, 12, 4096, true},
+		{4097, 8, 13, 8192, true},
+		{8, 128, 0, 0, false},
+		{8, 24, 0, 0, false},
+		{^uintptr(0), 8, 0, 0, false},
+	}
+	for _, tt := range tests {
+		class, blockSize, ok := sizeClass(tt.size, tt.alignment)
+		require.Equalf(t, tt.ok, ok, "size %d alignment %d", tt.size, tt.alignment)
+		require.Equalf(t, tt.class, class, "size %d alignment %d", tt.size, tt.alignment)
+		require.Equalf(t, tt.blockSize, blockSize, "size %d alignment %d", tt.size, tt.alignment)
+	}
+}
+
+func TestFreeListArenaReusesFreedBlocks(t *testing.T) {
+	arena := NewFreeListArena(WithMinBufferSize(1024))
+
+	ptr1 := arena.Alloc(24, 8)
+	require.NotNil(t, ptr1)
+	require.Equal(t, 32, arena.Len())
+	ptr2 := arena.Alloc(24, 8)
+	require.Equal(t, 64, arena.Len())
+
+	region := unsafe.Slice((*byte)(ptr1), 24)
+	for i := range region {
+		region[i] = 0xff
+	}
+	arena.(Freer).Free(ptr1, 24, 8)
+	require.Equal(t, 32, arena.Len())
+
+	// Same class, different size: served from the free list and zeroed.
+	ptr3 := arena.Alloc(20, 4)
+	require.Equal(t, ptr1, ptr3)
+	require.Equal(t, make([]byte, 20), unsafe.Slice((*byte)(ptr3), 20))
+	require.Equal(t, 64, arena.Len())
+	require.Equal(t, 64, arena.Peak())
+
+	// Different class: carved from the buffer.
+	ptr4 := arena.Alloc(100, 8)
+	require.NotEqual(t, ptr2, ptr4)
+	require.Zero(t, uintptr(ptr4)%64)
+	require.Equal(t, 192, arena.Len())
+}
+
+func TestFreeListArenaFreeListOrder(t *testing.T) {
+	arena := NewFreeListArena()
+
+	ptrs := make([]unsafe.Pointer, 4)
+	for i := range ptrs {
+		ptrs[i] = arena.Alloc(16, 8)
+	}
+	for _, p := range ptrs {
+		arena.(Freer).Free(p, 16, 8)
+	}
+	require.Equal(t, 0, arena.Len())
+	capBefore := arena.Cap()
+
+	for i := len(ptrs) - 1; i >= 0; i-- {
+		require.Equal(t, ptrs[i], arena.Alloc(16, 8))
+	}
+	require.Equal(t, capBefore, arena.Cap())
+}
+
+func TestFreeListArenaUnsupportedAlignment(t *testing.T) {
+	arena := NewFreeListArena()
+
+	require.Nil(t, arena.Alloc(0, 8))
+	require.Nil(t, arena.Alloc(8, 128))
+	require.Nil(t, arena.Alloc(8, 3))
+	require.Equal(t, 0, arena.Len())
+}
+
+func TestFreeListArenaReset(t *testing.T) {
+	arena := NewFreeListArena()
+
+	ptr := arena.Alloc(16, 8)
+	arena.(Freer).Free(ptr, 16, 8)
+	arena.Alloc(64, 8)
+	peak := arena.Peak()
+
+	arena.Reset()
+	require.Equal(t, 0, arena.Len())
+	require.Equal(t, peak, arena.Peak())
+
+	// Free lists are dropped; the buffer is reused from the start.
+	require.Equal(t, ptr, arena.Alloc(16, 8))
+	require.Equal(t, 16, arena.Len())
+
+	r := AllocateRef[int](arena)
+	arena.Release()
+	require.False(t, r.Valid())
+	require.Equal(t, 0, arena.Len())
+}
+
+func TestFree(t *testing.T) {
+	arena := NewFreeListArena()
+
+	v := Allocate[int64](arena)
+	Free(arena, v)
+	require.Equal(t, 0, arena.Len())
+	require.Equal(t, unsafe.Pointer(v), unsafe.Pointer(Allocate[int64](arena)))
+
+	s := AllocateSlice[int32](arena, 3, 10)
+	require.Equal(t, 8+64, arena.Len())
+	FreeSlice(arena, s)
+	require.Equal(t, 8, arena.Len())
+
+	// Values that don't come from arena buffers are left alone.
+	p := Allocate[pointerStruct](arena)
+	Free(arena, p)
+	FreeSlice(arena, AllocateSlice[string](arena, 0, 4))
+	Free(arena, Allocate[struct{}](arena))
+	Free[int](arena, nil)
+	require.Equal(t, 8, arena.Len())
+}
+
+func TestFreeWithoutFreer(t *testing.T) {
+	arena := NewMonotonicArena()
+
+	v := Allocate[int64](arena)
+	Free(arena, v)
+	FreeSlice(arena, AllocateSlice[byte](arena, 1, 8))
+	Free[int](nil, nil)
+	require.Equal(t, 16, arena.Len())
+
+	// concurrentArena forwards to Freer implementations only.
+	concurrent := NewConcurrentArena(arena)
+	Free(concurrent, Allocate[int64](concurrent))
+	require.Equal(t, 24, concurrent.Len())
+
+	concurrent = NewConcurrentArena(NewFreeListArena())
+	Free(concurrent, Allocate[int64](concurrent))
+	require.Equal(t, 0, concurrent.Len())
+}
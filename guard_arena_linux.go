@@ -0,0 +1,136 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package arena
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+// guardArena hands out memory from anonymous mappings and revokes access to
+// them on Reset and Release instead of clearing them. Any access through a
+// pointer that outlived the arena's state then faults immediately, with a
+// stack trace pointing at the offending code.
+type guardArena struct {
+	buffers    []*monotonicBuffer // live mappings, the last one is allocated from
+	totalAlloc uintptr
+	peak       uintptr // peak of earlier cycles
+	curPeak    uintptr // peak since the last Reset or Release
+	gen        uint64
+}
+
+// NewGuardArena returns an arena for hunting use-after-free bugs. It is a
+// debugging tool and only available on Linux.
+//
+// Memory comes from anonymous mmap regions. Reset and Release don't recycle
+// them: the pages are returned to the OS and protected with PROT_NONE, so any
+// later read or write through a stale pointer crashes the process with a
+// fault at the offending access (or panics, if debug.SetPanicOnFault is
+// enabled). Because released address ranges are never reused, the arena
+// consumes fresh virtual address space on every cycle; physical memory is
+// returned on every Reset.
+//
+// Types containing pointers are allocated on the heap, as the garbage
+// collector does not scan mapped memory.
+func NewGuardArena() Arena {
+	return &guardArena{}
+}
+
+// Alloc satisfies the Arena interface.
+func (a *guardArena) Alloc(size, alignment uintptr) unsafe.Pointer {
+	if size == 0 {
+		return nil
+	}
+	if n := len(a.buffers); n > 0 {
+		if ptr, consumed, ok := a.buffers[n-1].alloc(size, alignment); ok {
+			a.account(consumed)
+			return ptr
+		}
+	}
+
+	regionSize := size
+	if alignment > 1 {
+		regionSize += alignment - 1
+		if regionSize < size {
+			return nil
+		}
+	}
+	buf := newMappedBuffer(max(regionSize, minBufferSize))
+	if buf == nil {
+		return nil
+	}
+	a.buffers = append(a.buffers, buf)
+
+	ptr, consumed, _ := buf.alloc(size, alignment)
+	a.account(consumed)
+	return ptr
+}
+
+func (a *guardArena) account(consumed uintptr) {
+	a.totalAlloc += consumed
+	if a.totalAlloc > a.curPeak {
+		a.curPeak = a.totalAlloc
+	}
+}
+
+// revoke drops all physical pages and makes every mapping inaccessible.
+// The mappings are deliberately never unmapped: a new mapping could land on
+// the same addresses and turn a stale access back into a silent one.
+func (a *guardArena) revoke() {
+	for _, s := range a.buffers {
+		mem := s.mapping()
+		if err := syscall.Madvise(mem, syscall.MADV_DONTNEED); err != nil {
+			panic("arena: guard arena failed to discard released memory: " + err.Error())
+		}
+		if err := syscall.Mprotect(mem, syscall.PROT_NONE); err != nil {
+			panic("arena: guard arena failed to protect released memory: " + err.Error())
+		}
+	}
+	a.buffers = a.buffers[:0]
+	a.peak = max(a.peak, a.curPeak)
+	a.curPeak = 0
+	a.totalAlloc = 0
+	a.gen++
+}
+
+// Reset satisfies the Arena interface.
+// Unlike other arenas, it does not keep the memory for reuse.
+func (a *guardArena) Reset() {
+	a.revoke()
+}
+
+// Release satisfies the Arena interface.
+func (a *guardArena) Release() {
+	a.revoke()
+	a.buffers = nil
+}
+
+// Len returns the total number of bytes currently allocated in the arena.
+func (a *guardArena) Len() int {
+	return int(a.totalAlloc)
+}
+
+// Cap returns the total capacity (maximum bytes) that can be allocated in the arena.
+func (a *guardArena) Cap() int {
+	var total uintptr
+	for _, s := range a.buffers {
+		total += s.size
+	}
+	return int(total)
+}
+
+// Peak returns the peak number of bytes that have been allocated in the arena.
+// This value is not reset when Reset is called, allowing tracking of maximum usage.
+func (a *guardArena) Peak() int {
+	return int(max(a.peak, a.curPeak))
+}
+
+// cyclePeak satisfies the cyclePeaker interface.
+func (a *guardArena) cyclePeak() int {
+	return int(a.curPeak)
+}
+
+// generation satisfies the generational interface.
+func (a *guardArena) generation() uint64 {
+	return a.gen
+}
@@ -0,0 +1,104 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package arena
+
+import (
+	"runtime/debug"
+	"sync"
+	"testing"
+	"unsafe"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGuardArenaAlloc(t *testing.T) {
+	arena := NewGuardArena()
+	defer arena.Release()
+
+	require.Nil(t, arena.Alloc(0, 1))
+
+	ptr := arena.Alloc(100, 1)
+	require.NotNil(t, ptr)
+	require.Equal(t, 100, arena.Len())
+	require.GreaterOrEqual(t, arena.Cap(), minBufferSize)
+
+	ptr2 := arena.Alloc(8, 64)
+	require.Zero(t, uintptr(ptr2)%64)
+
+	// Requests larger than a region get a dedicated one.
+	big := arena.Alloc(minBufferSize*2, 8)
+	require.NotNil(t, big)
+	for i, b := range unsafe.Slice((*byte)(big), minBufferSize*2) {
+		if b != 0 {
+			require.Failf(t, "memory not zeroed", "byte %d", i)
+		}
+	}
+	require.Equal(t, arena.Len(), arena.Peak())
+}
+
+func TestGuardArenaFaultsAfterReset(t *testing.T) {
+	defer debug.SetPanicOnFault(debug.SetPanicOnFault(true))
+
+	arena := NewGuardArena()
+	v := Allocate[int64](arena)
+	*v = 42
+	peak := arena.Peak()
+
+	arena.Reset()
+	require.Equal(t, 0, arena.Len())
+	require.Equal(t, 0, arena.Cap())
+	require.Equal(t, peak, arena.Peak())
+	require.Panics(t, func() {
+		_ = *v
+	})
+
+	// The arena stays usable, with fresh memory.
+	v2 := Allocate[int64](arena)
+	require.NotEqual(t, unsafe.Pointer(v), unsafe.Pointer(v2))
+	require.Equal(t, int64(0), *v2)
+}
+
+func TestGuardArenaFaultsAfterRelease(t *testing.T) {
+	defer debug.SetPanicOnFault(debug.SetPanicOnFault(true))
+
+	arena := NewGuardArena()
+	s := AllocateSlice[byte](arena, 16, 16)
+
+	arena.Release()
+	require.Panics(t, func() {
+		s[0] = 1
+	})
+}
+
+func TestGuardArenaPointerTypesUseHeap(t *testing.T) {
+	arena := NewGuardArena()
+	defer arena.Release()
+
+	v := Allocate[pointerStruct](arena)
+	v.Name = "name"
+	arena.Reset()
+	require.Equal(t, "name", v.Name)
+}
+
+func TestGuardArenaConcurrent(t *testing.T) {
+	arena := NewConcurrentArena(NewGuardArena())
+	defer arena.Release()
+
+	var wg sync.WaitGroup
+	for i := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range 100 {
+				v := Allocate[int](arena)
+				*v = i * j
+			}
+		}()
+	}
+	wg.Wait()
+	require.Equal(t, 8*100*int(unsafe.Sizeof(0)), arena.Len())
+
+	r := AllocateRef[int](arena)
+	arena.Reset()
+	require.False(t, r.Valid())
+}
@@ -0,0 +1,235 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package arena
+
+import (
+	"runtime"
+	"syscall"
+	"unsafe"
+)
+
+// mmapArena is a monotonic arena whose buffers live outside the Go heap, in
+// anonymous memory mappings. Its memory doesn't count towards the heap size
+// the garbage collector paces itself on.
+type mmapArena struct {
+	*mmapBuffers
+	cleanup       *runtime.Cleanup // unmaps the buffers if the arena is dropped without Release
+	totalAlloc    uintptr
+	peak          uintptr // peak of earlier cycles
+	curPeak       uintptr // peak since the last Reset or Release
+	cursor        int     // same role as monotonicArena.cursor
+	bufferSize    uintptr
+	highWaterMark uintptr
+	gen           uint64
+}
+
+// mmapBuffers holds the mappings of an mmap arena apart from the arena, so
+// that the cleanup unmapping them doesn't keep the arena reachable.
+type mmapBuffers struct {
+	buffers []*monotonicBuffer
+}
+
+// unmap unmaps all buffers.
+func (b *mmapBuffers) unmap() error {
+	for i, s := range b.buffers {
+		if err := syscall.Munmap(s.mapping()); err != nil {
+			b.buffers = b.buffers[i:]
+			return err
+		}
+	}
+	b.buffers = nil
+	return nil
+}
+
+// MmapArenaOption represents a configuration option for an mmap arena.
+type MmapArenaOption func(*mmapArena)
+
+// WithMmapBufferSize sets the minimum size of the mappings created by the arena.
+// Sizes are rounded up to whole pages.
+func WithMmapBufferSize(size int) MmapArenaOption {
+	return func(a *mmapArena) {
+		a.bufferSize = uintptr(size)
+	}
+}
+
+// WithMmapHighWaterMark makes Reset hand the physical pages of all buffers
+// beyond the first size bytes of capacity back to the OS with
+// madvise(MADV_DONTNEED) instead of clearing them. The mappings are kept, so
+// the arena's capacity doesn't change, but a single oversized request no
+// longer pins its memory for the lifetime of the arena.
+func WithMmapHighWaterMark(size int) MmapArenaOption {
+	return func(a *mmapArena) {
+		a.highWaterMark = uintptr(size)
+	}
+}
+
+// NewMmapArena creates a new arena backed by anonymous mmap regions instead of
+// the Go heap. It is only available on Linux.
+//
+// It behaves like a monotonic arena, but Release unmaps all buffers and
+// returns their memory to the OS right away instead of leaving it to the
+// garbage collector. This keeps the heap, and with it the GC's heap target,
+// flat for large pointer-free payloads such as JSON bytes. Types containing
+// pointers are allocated on the heap, as the garbage collector does not scan
+// mapped memory.
+//
+// Arenas that become unreachable without being released, e.g. when a Pool
+// lets the GC collect them, have their buffers unmapped by a cleanup.
+//
+// If no options are provided, buffers are minBufferSize (32KB) large and
+// Reset keeps all pages.
+func NewMmapArena(opts ...MmapArenaOption) Arena {
+	a := &mmapArena{
+		mmapBuffers: &mmapBuffers{},
+		bufferSize:  minBufferSize,
+	}
+	for _, opt := range opts {
+		opt(a)
+	}
+	return a
+}
+
+// newMappedBuffer maps a zeroed, private anonymous region of at least size
+// bytes. It returns nil if the mapping fails.
+func newMappedBuffer(size uintptr) *monotonicBuffer {
+	pageSize := uintptr(syscall.Getpagesize())
+	if size > uintptr(maxInt)-pageSize {
+		return nil
+	}
+	size = (size + pageSize - 1) &^ (pageSize - 1)
+	mem, err := syscall.Mmap(-1, 0, int(size), syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_ANON|syscall.MAP_PRIVATE)
+	if err != nil {
+		return nil
+	}
+	return &monotonicBuffer{ptr: unsafe.Pointer(unsafe.SliceData(mem)), size: size}
+}
+
+// mapping returns the whole region backing a mapped buffer.
+func (s *monotonicBuffer) mapping() []byte {
+	return unsafe.Slice((*byte)(s.ptr), s.size)
+}
+
+// Alloc satisfies the Arena interface.
+func (a *mmapArena) Alloc(size, alignment uintptr) unsafe.Pointer {
+	if size == 0 {
+		return nil
+	}
+	for i := a.cursor; i < len(a.buffers); i++ {
+		ptr, consumed, ok := a.buffers[i].alloc(size, alignment)
+		if ok {
+			a.cursor = i
+			a.account(consumed)
+			return ptr
+		}
+	}
+
+	// Mappings are page aligned, so the alignment margin is only needed for
+	// alignments above the page size; it is kept for symmetry with
+	// monotonicArena.
+	newBufferSize := size
+	if alignment > 1 {
+		newBufferSize += alignment - 1
+		if newBufferSize < size {
+			return nil
+		}
+	}
+	newBuffer := newMappedBuffer(max(newBufferSize, a.bufferSize))
+	if newBuffer == nil {
+		return nil
+	}
+	a.buffers = append(a.buffers, newBuffer)
+	a.cursor = len(a.buffers) - 1
+	if a.cleanup == nil {
+		cleanup := runtime.AddCleanup(a, func(b *mmapBuffers) {
+			// Nobody can reference the memory anymore, so there's
+			// nobody to report a failure to either.
+			_ = b.unmap()
+		}, a.mmapBuffers)
+		a.cleanup = &cleanup
+	}
+
+	ptr, consumed, _ := newBuffer.alloc(size, alignment)
+	a.account(consumed)
+	return ptr
+}
+
+func (a *mmapArena) account(consumed uintptr) {
+	a.totalAlloc += consumed
+	if a.totalAlloc > a.curPeak {
+		a.curPeak = a.totalAlloc
+	}
+}
+
+// Reset satisfies the Arena interface.
+func (a *mmapArena) Reset() {
+	var capacity uintptr
+	for _, s := range a.buffers {
+		capacity += s.size
+		if a.highWaterMark == 0 || capacity <= a.highWaterMark || s.offset == 0 {
+			s.reset()
+			continue
+		}
+		// Private anonymous pages read back as zeroes after MADV_DONTNEED,
+		// which keeps the zero invariant without touching them.
+		if err := syscall.Madvise(s.mapping(), syscall.MADV_DONTNEED); err != nil {
+			s.reset()
+			continue
+		}
+		s.offset = 0
+	}
+	a.endCycle()
+	a.cursor = 0
+	a.gen++
+}
+
+// Release satisfies the Arena interface.
+// It unmaps all buffers; any pointer into them faults afterwards.
+func (a *mmapArena) Release() {
+	if a.cleanup != nil {
+		a.cleanup.Stop()
+		a.cleanup = nil
+	}
+	if err := a.unmap(); err != nil {
+		panic("arena: mmap arena failed to unmap buffer: " + err.Error())
+	}
+	a.endCycle()
+	a.cursor = 0
+	a.gen++
+}
+
+// Len returns the total number of bytes currently allocated in the arena.
+func (a *mmapArena) Len() int {
+	return int(a.totalAlloc)
+}
+
+// Cap returns the total capacity (maximum bytes) that can be allocated in the arena.
+func (a *mmapArena) Cap() int {
+	var total uintptr
+	for _, s := range a.buffers {
+		total += s.size
+	}
+	return int(total)
+}
+
+// Peak returns the peak number of bytes that have been allocated in the arena.
+// This value is not reset when Reset is called, allowing tracking of maximum usage.
+func (a *mmapArena) Peak() int {
+	return int(max(a.peak, a.curPeak))
+}
+
+// cyclePeak satisfies the cyclePeaker interface.
+func (a *mmapArena) cyclePeak() int {
+	return int(a.curPeak)
+}
+
+// endCycle is like monotonicArena.endCycle.
+func (a *mmapArena) endCycle() {
+	a.peak = max(a.peak, a.curPeak)
+	a.curPeak = 0
+	a.totalAlloc = 0
+}
+
+// generation satisfies the generational interface.
+func (a *mmapArena) generation() uint64 {
+	return a.gen
+}
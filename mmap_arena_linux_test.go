@@ -0,0 +1,132 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package arena
+
+import (
+	"runtime"
+	"runtime/debug"
+	"syscall"
+	"testing"
+	"time"
+	"unsafe"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMmapArenaAlloc(t *testing.T) {
+	arena := NewMmapArena(WithMmapBufferSize(1000))
+	defer arena.Release()
+
+	require.Equal(t, 0, arena.Cap())
+	require.Nil(t, arena.Alloc(0, 1))
+
+	ptr := arena.Alloc(100, 1)
+	require.NotNil(t, ptr)
+	require.Equal(t, 100, arena.Len())
+	// Buffer sizes are rounded up to whole pages.
+	require.Equal(t, syscall.Getpagesize(), arena.Cap())
+
+	ptr2 := arena.Alloc(8, 16)
+	require.Zero(t, uintptr(ptr2)%16)
+
+	big := arena.Alloc(uintptr(syscall.Getpagesize())*2, 1)
+	require.NotNil(t, big)
+	require.Equal(t, 3*syscall.Getpagesize(), arena.Cap())
+	require.Equal(t, arena.Len(), arena.Peak())
+}
+
+func TestMmapArenaZeroAfterReset(t *testing.T) {
+	arena := NewMmapArena()
+	defer arena.Release()
+
+	s := AllocateSlice[byte](arena, 64, 64)
+	for i := range s {
+		s[i] = 0xff
+	}
+	capBefore := arena.Cap()
+
+	arena.Reset()
+	require.Equal(t, 0, arena.Len())
+	require.Equal(t, capBefore, arena.Cap())
+
+	s2 := AllocateSlice[byte](arena, 64, 64)
+	require.Equal(t, unsafe.SliceData(s), unsafe.SliceData(s2))
+	require.Equal(t, make([]byte, 64), s2)
+}
+
+func TestMmapArenaHighWaterMark(t *testing.T) {
+	pageSize := syscall.Getpagesize()
+	arena := NewMmapArena(WithMmapBufferSize(pageSize), WithMmapHighWaterMark(pageSize))
+	defer arena.Release()
+
+	first := AllocateSlice[byte](arena, pageSize, pageSize)
+	second := AllocateSlice[byte](arena, pageSize, pageSize)
+	for i := range first {
+		first[i] = 1
+		second[i] = 2
+	}
+	require.Equal(t, 2*pageSize, arena.Cap())
+
+	arena.Reset()
+	// The buffer beyond the mark is discarded but stays mapped and zeroed.
+	require.Equal(t, 2*pageSize, arena.Cap())
+	require.Equal(t, make([]byte, pageSize), first)
+	require.Equal(t, make([]byte, pageSize), second)
+
+	again := AllocateSlice[byte](arena, pageSize, pageSize)
+	again2 := AllocateSlice[byte](arena, pageSize, pageSize)
+	require.Equal(t, unsafe.SliceData(first), unsafe.SliceData(again))
+	require.Equal(t, unsafe.SliceData(second), unsafe.SliceData(again2))
+}
+
+func TestMmapArenaRelease(t *testing.T) {
+	defer debug.SetPanicOnFault(debug.SetPanicOnFault(true))
+
+	arena := NewMmapArena()
+	v := Allocate[int64](arena)
+	*v = 1
+
+	arena.Release()
+	require.Equal(t, 0, arena.Cap())
+	require.Equal(t, 0, arena.Len())
+	require.Panics(t, func() {
+		_ = *v
+	})
+
+	// The arena maps new memory when used again.
+	v2 := Allocate[int64](arena)
+	require.Equal(t, int64(0), *v2)
+	arena.Release()
+}
+
+func TestMmapArenaUnmappedWhenCollected(t *testing.T) {
+	arena := NewMmapArena()
+	Allocate[int64](arena)
+	mapping := arena.(*mmapArena).buffers[0].mapping()
+	arena = nil
+
+	// madvise fails with ENOMEM for unmapped ranges.
+	require.Eventually(t, func() bool {
+		runtime.GC()
+		return syscall.Madvise(mapping, syscall.MADV_NORMAL) == syscall.ENOMEM
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestMmapArenaReleaseStopsCleanup(t *testing.T) {
+	arena := NewMmapArena()
+	Allocate[int64](arena)
+	require.NotNil(t, arena.(*mmapArena).cleanup)
+	arena.Release()
+	require.Nil(t, arena.(*mmapArena).cleanup)
+}
+
+func TestMmapArenaPointerTypesUseHeap(t *testing.T) {
+	arena := NewMmapArena()
+
+	v := Allocate[pointerStruct](arena)
+	v.Name = "name"
+	require.Equal(t, 0, arena.Len())
+
+	arena.Release()
+	require.Equal(t, "name", v.Name)
+}
@@ -3,13 +3,17 @@
 package arena
 
 import (
+	"fmt"
+	"os"
+	"reflect"
 	"unsafe"
 )
 
 type monotonicArena struct {
 	buffers            []*monotonicBuffer
 	totalAlloc         uintptr // running sum of s.offset across all buffers; avoids O(buffers) scans on the hot path
-	peak               uintptr // tracks peak allocated space
+	peak               uintptr // tracks peak allocated space of earlier cycles
+	curPeak            uintptr // tracks peak allocated space since the last Reset or Release
 	minBufferSize      uintptr // minimum size for new buffers
 	initialBufferCount int     // number of initial buffers to create
 	// cursor is the index of the buffer where the most recent Alloc found
@@ -21,6 +25,25 @@ type monotonicArena struct {
 	// allocations of roughly uniform size that still fit at cursor, this
 	// reduces per-Alloc cost from O(len(buffers)) to O(1).
 	cursor int
+	// slabs holds GC-scanned memory for pointer-bearing types, keyed by type.
+	// It is created on first use, so pointer-free workloads never pay for it.
+	slabs         map[reflect.Type]scanSlab
+	pointerPolicy PointerPolicy
+	// gen counts calls to Reset and Release; Ref compares it to detect use
+	// of memory that was handed out before the arena was reset.
+	gen uint64
+	// poison makes Reset and Release fill released regions with poisonByte
+	// instead of zeroes, so stale reads stand out. Alloc then zeroes each
+	// region it hands out.
+	poison bool
+	// grow, if set, provides the zeroed memory for buffers created by Alloc
+	// instead of make. Sub-arenas use it to carve their buffers out of the
+	// parent arena.
+	grow func(size uintptr) unsafe.Pointer
+	// marks holds the sequence numbers of the checkpoints that can still be
+	// rewound to, from the oldest; markSeq numbers them.
+	marks   []uint64
+	markSeq uint64
 }
 
 type monotonicBuffer struct {
@@ -33,6 +56,18 @@ func newMonotonicBuffer(size int) *monotonicBuffer {
 	return &monotonicBuffer{size: uintptr(size)}
 }
 
+// materialize allocates the buffer's memory if it hasn't been yet.
+func (s *monotonicBuffer) materialize() bool {
+	if s.ptr == nil {
+		if s.size > uintptr(maxInt) {
+			return false
+		}
+		buf := make([]byte, s.size) // allocate monotonic buffer lazily
+		s.ptr = unsafe.Pointer(unsafe.SliceData(buf))
+	}
+	return true
+}
+
 // alloc reserves size bytes aligned to alignment and returns a pointer to the
 // start of the region along with the total bytes consumed (size + alignment padding).
 // The returned memory is guaranteed to be zeroed: freshly allocated buffers come
@@ -40,12 +75,8 @@ func newMonotonicBuffer(size int) *monotonicBuffer {
 // the buffer in a single memclr, so the invariant "bytes at [offset, size) are
 // zero" holds at the start of every alloc.
 func (s *monotonicBuffer) alloc(size, alignment uintptr) (unsafe.Pointer, uintptr, bool) {
-	if s.ptr == nil {
-		if s.size > uintptr(maxInt) {
-			return nil, 0, false
-		}
-		buf := make([]byte, s.size) // allocate monotonic buffer lazily
-		s.ptr = unsafe.Pointer(unsafe.SliceData(buf))
+	if !s.materialize() {
+		return nil, 0, false
 	}
 	// O(1) alignment: round offset up to the next multiple of alignment.
 	// Works for any positive alignment (power-of-2 or not).
@@ -85,6 +116,25 @@ func (s *monotonicBuffer) reset() {
 	s.offset = 0
 }
 
+// rewind discards everything allocated at or after offset. The discarded
+// bytes are zeroed to keep the zero invariant or, if poison is set, filled with
+// poisonByte. Poisoning breaks the invariant, so an arena with poisoning
+// enabled zeroes every region it hands out.
+func (s *monotonicBuffer) rewind(offset uintptr, poison bool) {
+	if s.offset <= offset {
+		return
+	}
+	discarded := unsafe.Slice((*byte)(unsafe.Add(s.ptr, offset)), s.offset-offset)
+	if poison {
+		for i := range discarded {
+			discarded[i] = poisonByte
+		}
+	} else {
+		clear(discarded)
+	}
+	s.offset = offset
+}
+
 func (s *monotonicBuffer) release() {
 	s.offset = 0
 	s.ptr = nil
@@ -114,6 +164,7 @@ func NewMonotonicArena(opts ...MonotonicArenaOption) Arena {
 const (
 	minBufferSize = 1024 * 32 // 32KB
 	maxInt        = int(^uint(0) >> 1)
+	poisonByte    = 0xde
 )
 
 // MonotonicArenaOption represents a configuration option for a monotonic arena.
@@ -133,6 +184,36 @@ func WithInitialBufferCount(count int) MonotonicArenaOption {
 	}
 }
 
+// WithPoisonOnReset makes Reset and Release fill the released memory with a
+// poison pattern instead of zeroes, so that reads through pointers that
+// outlived the arena's state return obvious garbage. It is meant for debugging
+// and costs an extra clear per allocation. Memory of pointer-bearing types is
+// zeroed instead, as the garbage collector must never see garbage pointers.
+func WithPoisonOnReset() MonotonicArenaOption {
+	return func(a *monotonicArena) {
+		a.poison = true
+	}
+}
+
+// WithPointerPolicy sets how Allocate and AllocateSlice handle types that contain pointers.
+func WithPointerPolicy(policy PointerPolicy) MonotonicArenaOption {
+	return func(a *monotonicArena) {
+		a.pointerPolicy = policy
+	}
+}
+
+// requireBufferOptions panics if opts set more than the buffer sizes, for
+// arenas that take their options from NewMonotonicArena but only support
+// those. PointerPolicyHeap is accepted, as it's what these arenas do anyway.
+func (a *monotonicArena) requireBufferOptions(constructor string) {
+	if a.poison {
+		panic("arena: " + constructor + " doesn't support WithPoisonOnReset")
+	}
+	if a.pointerPolicy == PointerPolicyPanic {
+		panic("arena: " + constructor + " doesn't support PointerPolicyPanic")
+	}
+}
+
 // Alloc satisfies the Arena interface.
 func (a *monotonicArena) Alloc(size, alignment uintptr) unsafe.Pointer {
 	// Zero-size allocations are a no-op. Returning nil tells the caller
@@ -146,10 +227,13 @@ func (a *monotonicArena) Alloc(size, alignment uintptr) unsafe.Pointer {
 	for i := a.cursor; i < len(a.buffers); i++ {
 		ptr, consumed, ok := a.buffers[i].alloc(size, alignment)
 		if ok {
+			if a.poison {
+				clear(unsafe.Slice((*byte)(ptr), size))
+			}
 			a.cursor = i
 			a.totalAlloc += consumed
-			if a.totalAlloc > a.peak {
-				a.peak = a.totalAlloc
+			if a.totalAlloc > a.curPeak {
+				a.curPeak = a.totalAlloc
 			}
 			return ptr
 		}
@@ -181,35 +265,194 @@ func (a *monotonicArena) Alloc(size, alignment uintptr) unsafe.Pointer {
 	}
 
 	newBuffer := newMonotonicBuffer(int(newBufferSize))
+	if a.grow != nil {
+		if newBuffer.ptr = a.grow(newBufferSize); newBuffer.ptr == nil {
+			return nil
+		}
+	}
 	a.buffers = append(a.buffers, newBuffer)
 	a.cursor = len(a.buffers) - 1
 
 	ptr, consumed, _ := newBuffer.alloc(size, alignment)
+	if a.poison {
+		clear(unsafe.Slice((*byte)(ptr), size))
+	}
 
 	a.totalAlloc += consumed
-	if a.totalAlloc > a.peak {
-		a.peak = a.totalAlloc
+	if a.totalAlloc > a.curPeak {
+		a.curPeak = a.totalAlloc
 	}
 
 	return ptr
 }
 
+// allocScan satisfies the scanAllocator interface.
+func (a *monotonicArena) allocScan(typ reflect.Type, n int, newSlab func() scanSlab) unsafe.Pointer {
+	switch a.pointerPolicy {
+	case PointerPolicyHeap:
+		return nil
+	case PointerPolicyPanic:
+		panic(fmt.Sprintf("arena: allocation of pointer-bearing type %s", typ))
+	}
+	s, ok := a.slabs[typ]
+	if !ok {
+		if a.slabs == nil {
+			a.slabs = make(map[reflect.Type]scanSlab)
+		}
+		s = newSlab()
+		a.slabs[typ] = s
+	}
+	ptr, consumed := s.alloc(n)
+	a.totalAlloc += consumed
+	if a.totalAlloc > a.curPeak {
+		a.curPeak = a.totalAlloc
+	}
+	return ptr
+}
+
 // Reset satisfies the Arena interface.
 func (a *monotonicArena) Reset() {
 	for _, s := range a.buffers {
+		if a.poison {
+			s.rewind(0, true)
+		} else {
+			s.reset()
+		}
+	}
+	for _, s := range a.slabs {
 		s.reset()
 	}
-	a.totalAlloc = 0
+	a.endCycle()
 	a.cursor = 0
+	a.marks = a.marks[:0]
+	a.gen++
 }
 
 // Release satisfies the Arena interface.
 func (a *monotonicArena) Release() {
+	if a.poison {
+		// Released memory stays alive for as long as stale pointers
+		// reference it, so poison it like on Reset.
+		for _, s := range a.buffers {
+			s.rewind(0, true)
+		}
+		for _, s := range a.slabs {
+			s.reset()
+		}
+	}
 	for _, s := range a.buffers {
 		s.release()
 	}
-	a.totalAlloc = 0
+	a.slabs = nil
+	a.endCycle()
 	a.cursor = 0
+	a.marks = a.marks[:0]
+	a.gen++
+}
+
+// Mark satisfies the Rewinder interface.
+func (a *monotonicArena) Mark() Checkpoint {
+	a.markSeq++
+	cp := Checkpoint{
+		cursor:     a.cursor,
+		totalAlloc: a.totalAlloc,
+		gen:        a.gen,
+		depth:      len(a.marks),
+		seq:        a.markSeq,
+	}
+	a.marks = append(a.marks, a.markSeq)
+	if a.cursor < len(a.buffers) {
+		cp.offset = a.buffers[a.cursor].offset
+	}
+	for typ, s := range a.slabs {
+		cp.slabs = append(cp.slabs, slabCheckpoint{typ: typ, mark: s.mark()})
+	}
+	return cp
+}
+
+// Rewind satisfies the Rewinder interface.
+func (a *monotonicArena) Rewind(cp Checkpoint) {
+	if cp.gen != a.gen {
+		panic("arena: Rewind to a checkpoint taken before the last Reset or Release")
+	}
+	if cp.depth >= len(a.marks) || a.marks[cp.depth] != cp.seq {
+		panic("arena: Rewind to a checkpoint that was already rewound past")
+	}
+	// Checkpoints taken after cp are invalid from now on, cp stays valid.
+	a.marks = a.marks[:cp.depth+1]
+	// Buffers before the cursor are never allocated from again, so only the
+	// buffer at the checkpoint's cursor and the ones after it can have
+	// changed since Mark.
+	for i := cp.cursor; i < len(a.buffers); i++ {
+		var offset uintptr
+		if i == cp.cursor {
+			offset = cp.offset
+		}
+		a.buffers[i].rewind(offset, a.poison)
+	}
+	for typ, s := range a.slabs {
+		if mark, ok := cp.slabMark(typ); ok {
+			s.rewind(mark)
+		} else {
+			// The slab was created after Mark.
+			s.reset()
+		}
+	}
+	a.totalAlloc = cp.totalAlloc
+	a.cursor = cp.cursor
+}
+
+// Trim satisfies the Trimmer interface.
+func (a *monotonicArena) Trim(size int) {
+	if a.Cap() <= size {
+		return
+	}
+	var total uintptr
+	kept := a.buffers[:0]
+	for _, s := range a.buffers {
+		if total+s.size <= uintptr(size) {
+			total += s.size
+			kept = append(kept, s)
+		}
+	}
+	clear(a.buffers[len(kept):])
+	a.buffers = kept
+	for typ, s := range a.slabs {
+		if total+s.size() <= uintptr(size) {
+			total += s.size()
+		} else {
+			delete(a.slabs, typ)
+		}
+	}
+	// Arenas with a grow func get their buffers from elsewhere, so they're
+	// left to create new ones on demand.
+	if len(a.buffers) == 0 && a.grow == nil && size > 0 {
+		a.buffers = append(a.buffers, newMonotonicBuffer(size))
+	}
+	a.cursor = 0
+}
+
+// prewarm satisfies the prewarmer interface.
+func (a *monotonicArena) prewarm() {
+	if a.grow != nil {
+		return
+	}
+	pageSize := uintptr(os.Getpagesize())
+	for _, s := range a.buffers {
+		if s.ptr != nil || !s.materialize() {
+			continue
+		}
+		// Large buffers come zeroed from the OS, which only backs their
+		// pages with memory once they're written to.
+		for off := uintptr(0); off < s.size; off += pageSize {
+			*(*byte)(unsafe.Add(s.ptr, off)) = 0
+		}
+	}
+}
+
+// generation satisfies the generational interface.
+func (a *monotonicArena) generation() uint64 {
+	return a.gen
 }
 
 // Len returns the total number of bytes currently allocated in the arena.
@@ -223,11 +466,27 @@ func (a *monotonicArena) Cap() int {
 	for _, s := range a.buffers {
 		total += s.size
 	}
+	for _, s := range a.slabs {
+		total += s.size()
+	}
 	return int(total)
 }
 
 // Peak returns the peak number of bytes that have been allocated in the arena.
 // This value is not reset when Reset is called, allowing tracking of maximum usage.
 func (a *monotonicArena) Peak() int {
-	return int(a.peak)
+	return int(max(a.peak, a.curPeak))
+}
+
+// cyclePeak satisfies the cyclePeaker interface.
+func (a *monotonicArena) cyclePeak() int {
+	return int(a.curPeak)
+}
+
+// endCycle folds the peak of the current cycle into the lifetime peak and
+// starts a new, empty cycle.
+func (a *monotonicArena) endCycle() {
+	a.peak = max(a.peak, a.curPeak)
+	a.curPeak = 0
+	a.totalAlloc = 0
 }
@@ -3,8 +3,10 @@
 package arena
 
 import (
+	"bytes"
 	"fmt"
 	"math/rand/v2"
+	"reflect"
 	"testing"
 	"unsafe"
 
@@ -411,6 +413,29 @@ func TestMonotonicArenaPeakAfterReset(t *testing.T) {
 	require.Equal(t, 450, arena.Peak()) // Peak should not be reset
 }
 
+func TestMonotonicArenaCyclePeak(t *testing.T) {
+	arena := NewMonotonicArena().(*monotonicArena)
+
+	arena.Alloc(300, 1)
+	require.Equal(t, 300, arena.cyclePeak())
+
+	// Reset starts a new cycle, while Peak keeps the lifetime maximum.
+	arena.Reset()
+	require.Equal(t, 0, arena.cyclePeak())
+	arena.Alloc(50, 1)
+	require.Equal(t, 50, arena.cyclePeak())
+	require.Equal(t, 300, arena.Peak())
+
+	cp := arena.Mark()
+	arena.Alloc(100, 1)
+	arena.Rewind(cp)
+	require.Equal(t, 150, arena.cyclePeak())
+
+	arena.Release()
+	require.Equal(t, 0, arena.cyclePeak())
+	require.Equal(t, 300, arena.Peak())
+}
+
 func TestMonotonicArenaPeakMultipleBuffers(t *testing.T) {
 	// Create arena with multiple buffers
 	arena := NewMonotonicArena(WithInitialBufferCount(3), WithMinBufferSize(100)) // 3 buffers of 100 bytes each
@@ -1195,3 +1220,222 @@ func TestMonotonicArenaInitialBufferCountReset(t *testing.T) {
 	require.Equal(t, 0, arena.Len())
 	require.Equal(t, 2, len(arena.(*monotonicArena).buffers)) // Should still have 2 buffers (but memory released)
 }
+
+func TestMonotonicArenaPoisonOnReset(t *testing.T) {
+	arena := NewMonotonicArena(WithInitialBufferCount(1), WithMinBufferSize(64), WithPoisonOnReset())
+
+	ptr := arena.Alloc(16, 1)
+	region := unsafe.Slice((*byte)(ptr), 16)
+	for i := range region {
+		region[i] = byte(i + 1)
+	}
+
+	arena.Reset()
+	for i, b := range region {
+		require.Equalf(t, byte(poisonByte), b, "byte %d not poisoned after reset", i)
+	}
+
+	// Memory handed out again must still be zeroed.
+	ptr2 := arena.Alloc(16, 1)
+	require.Equal(t, ptr, ptr2)
+	for i, b := range unsafe.Slice((*byte)(ptr2), 16) {
+		require.Equalf(t, byte(0), b, "byte %d not zeroed after poisoning", i)
+	}
+
+	// New buffers are zeroed as well.
+	ptr3 := arena.Alloc(128, 1)
+	for i, b := range unsafe.Slice((*byte)(ptr3), 128) {
+		require.Equalf(t, byte(0), b, "byte %d not zeroed in new buffer", i)
+	}
+}
+
+func TestMonotonicArenaPoisonOnRelease(t *testing.T) {
+	arena := NewMonotonicArena(WithPoisonOnReset())
+
+	v := Allocate[int64](arena)
+	*v = 42
+	p := Allocate[pointerStruct](arena)
+	p.Name = "name"
+
+	arena.Release()
+	for i, b := range unsafe.Slice((*byte)(unsafe.Pointer(v)), unsafe.Sizeof(*v)) {
+		require.Equalf(t, byte(poisonByte), b, "byte %d not poisoned after release", i)
+	}
+	require.Equal(t, pointerStruct{}, *p)
+}
+
+func TestMonotonicArenaGenerationAdvances(t *testing.T) {
+	arena := NewMonotonicArena().(*monotonicArena)
+	require.Equal(t, uint64(0), arena.generation())
+
+	arena.Reset()
+	require.Equal(t, uint64(1), arena.generation())
+
+	arena.Release()
+	require.Equal(t, uint64(2), arena.generation())
+}
+
+func TestMonotonicArenaMarkRewind(t *testing.T) {
+	arena := NewMonotonicArena(WithInitialBufferCount(1), WithMinBufferSize(64))
+	rw, ok := arena.(Rewinder)
+	require.True(t, ok)
+
+	kept := arena.Alloc(16, 1)
+	region := unsafe.Slice((*byte)(kept), 16)
+	for i := range region {
+		region[i] = 1
+	}
+	cp := rw.Mark()
+
+	// Spill into a second and third buffer.
+	discarded := arena.Alloc(32, 1)
+	for i := range unsafe.Slice((*byte)(discarded), 32) {
+		*(*byte)(unsafe.Add(discarded, i)) = 2
+	}
+	arena.Alloc(64, 1)
+	arena.Alloc(64, 1)
+	require.Equal(t, 176, arena.Len())
+	capBefore := arena.Cap()
+
+	rw.Rewind(cp)
+	require.Equal(t, 16, arena.Len())
+	require.Equal(t, capBefore, arena.Cap(), "buffers are kept for reuse")
+	require.Equal(t, 176, arena.Peak())
+	require.Equal(t, bytes.Repeat([]byte{1}, 16), region, "allocations before Mark stay intact")
+	require.Equal(t, make([]byte, 32), unsafe.Slice((*byte)(discarded), 32), "discarded region must be zeroed")
+
+	// Allocation resumes right after the checkpoint.
+	require.Equal(t, discarded, arena.Alloc(8, 1))
+	require.Equal(t, 24, arena.Len())
+}
+
+func TestMonotonicArenaMarkRewindNested(t *testing.T) {
+	arena := NewMonotonicArena(WithMinBufferSize(1024))
+	rw := arena.(Rewinder)
+
+	outer := rw.Mark()
+	arena.Alloc(100, 1)
+	inner := rw.Mark()
+	arena.Alloc(100, 1)
+
+	rw.Rewind(inner)
+	require.Equal(t, 100, arena.Len())
+	rw.Rewind(outer)
+	require.Equal(t, 0, arena.Len())
+
+	require.PanicsWithValue(t, "arena: Rewind to a checkpoint that was already rewound past", func() {
+		rw.Rewind(inner)
+	})
+}
+
+func TestMonotonicArenaRewindInvalidatesLaterCheckpoints(t *testing.T) {
+	arena := NewMonotonicArena(WithMinBufferSize(1024))
+	rw := arena.(Rewinder)
+
+	first := rw.Mark()
+	arena.Alloc(100, 1)
+	second := rw.Mark()
+	rw.Rewind(first)
+
+	// The buffer's offset is past second's again, which must not make it
+	// valid.
+	arena.Alloc(150, 1)
+	require.PanicsWithValue(t, "arena: Rewind to a checkpoint that was already rewound past", func() {
+		rw.Rewind(second)
+	})
+	require.Equal(t, 150, arena.Len())
+
+	// first stays valid, and so do checkpoints taken after rewinding to it.
+	third := rw.Mark()
+	arena.Alloc(50, 1)
+	rw.Rewind(third)
+	require.Equal(t, 150, arena.Len())
+	rw.Rewind(first)
+	require.Equal(t, 0, arena.Len())
+}
+
+func TestMonotonicArenaMarkRewindEmptyArena(t *testing.T) {
+	arena := NewMonotonicArena(WithInitialBufferCount(0))
+	rw := arena.(Rewinder)
+
+	cp := rw.Mark()
+	arena.Alloc(100, 1)
+	rw.Rewind(cp)
+	require.Equal(t, 0, arena.Len())
+	require.NotNil(t, arena.Alloc(100, 1))
+}
+
+func TestMonotonicArenaRewindAfterReset(t *testing.T) {
+	arena := NewMonotonicArena()
+	rw := arena.(Rewinder)
+
+	cp := rw.Mark()
+	arena.Reset()
+	require.PanicsWithValue(t, "arena: Rewind to a checkpoint taken before the last Reset or Release", func() {
+		rw.Rewind(cp)
+	})
+}
+
+func TestMonotonicArenaRewindPoison(t *testing.T) {
+	arena := NewMonotonicArena(WithPoisonOnReset())
+	rw := arena.(Rewinder)
+
+	cp := rw.Mark()
+	ptr := arena.Alloc(8, 1)
+	rw.Rewind(cp)
+	for i, b := range unsafe.Slice((*byte)(ptr), 8) {
+		require.Equalf(t, byte(poisonByte), b, "byte %d not poisoned after rewind", i)
+	}
+	require.Equal(t, make([]byte, 8), unsafe.Slice((*byte)(arena.Alloc(8, 1)), 8))
+}
+
+func TestMonotonicArenaRewindPointerTypes(t *testing.T) {
+	arena := NewMonotonicArena()
+	rw := arena.(Rewinder)
+
+	kept := Allocate[pointerStruct](arena)
+	kept.Name = "kept"
+	cp := rw.Mark()
+
+	discarded := Allocate[pointerStruct](arena)
+	discarded.Name = "discarded"
+	other := AllocateSlice[*int](arena, 1, 1)
+	other[0] = new(int)
+
+	rw.Rewind(cp)
+	require.Equal(t, int(unsafe.Sizeof(pointerStruct{})), arena.Len())
+	require.Equal(t, "kept", kept.Name)
+	require.Equal(t, pointerStruct{}, *discarded)
+	require.Nil(t, other[0])
+
+	require.Equal(t, unsafe.Pointer(discarded), unsafe.Pointer(Allocate[pointerStruct](arena)))
+}
+
+func TestMonotonicArenaTrim(t *testing.T) {
+	arena := NewMonotonicArena(WithMinBufferSize(1024), WithInitialBufferCount(3))
+	arena.Alloc(4096, 1)
+	Allocate[pointerStruct](arena)
+	require.Greater(t, arena.Cap(), 3*1024+4096)
+	arena.Reset()
+
+	// Keeps the buffers that fit and drops the rest, including slabs.
+	arena.(Trimmer).Trim(2500)
+	require.Equal(t, 2048, arena.Cap())
+	m := arena.(*monotonicArena)
+	require.Len(t, m.buffers, 2)
+	require.Nil(t, m.slabs[reflect.TypeFor[pointerStruct]()])
+	require.NotNil(t, arena.Alloc(1024, 1))
+	require.NotNil(t, arena.Alloc(1024, 1))
+	arena.Reset()
+
+	// Nothing to do.
+	arena.(Trimmer).Trim(4096)
+	require.Equal(t, 2048, arena.Cap())
+
+	// No buffer fits, so they're consolidated into a single one.
+	arena.(Trimmer).Trim(512)
+	require.Equal(t, 512, arena.Cap())
+	require.Len(t, m.buffers, 1)
+	require.NotNil(t, arena.Alloc(512, 1))
+	require.Equal(t, 512, arena.Cap())
+}
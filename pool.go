@@ -1,7 +1,12 @@
 package arena
 
 import (
+	"context"
+	"math/bits"
+	"runtime"
 	"sync"
+	"sync/atomic"
+	"time"
 	"weak"
 )
 
@@ -15,128 +20,647 @@ import (
 // this means that at any time, GC can claim back the memory if required,
 // allowing GC to automatically manage an appropriate pool size depending on available memory and GC pressure
 type Pool struct {
-	// pool is a slice of weak pointers to the struct holding the arena.Arena
-	pool  []weak.Pointer[PoolItem]
-	sizes map[uint64]*arenaPoolItemSize
-	mu    sync.Mutex
+	// buckets holds weak pointers to the struct holding the arena.Arena,
+	// grouped by size class, the most recently released first
+	buckets [64]*poolEntry
+	// nonEmpty has bit i set if buckets[i] isn't empty
+	nonEmpty uint64
+	// oldest and newest link all entries by the time they were released
+	oldest, newest *poolEntry
+	count          int
+
+	// hotOldest is the oldest of the hot entries, the newest ones that are
+	// held strongly
+	hotOldest *poolEntry
+	hot       int
+	hotSize   int
+
+	sizes  map[uint64]*sizeEntry
+	sizing SizingStrategy
+	mu     sync.Mutex
+
+	// sizeLRU links the entries of sizes from most to least recently used
+	sizeLRU     sizeEntry
+	maxSizeKeys int
+
+	// gcRan is set after every GC cycle, after which pooled arenas may
+	// have been collected, see watchGC
+	gcRan *atomic.Bool
+
+	// bytes is the total capacity of the pooled arenas, as of their release
+	bytes    int
+	maxItems int
+	maxBytes int
+	eviction EvictionPolicy
+
+	newArena    func(sizeHint int) Arena
+	defaultSize int
+	minSize     int
+	maxSize     int
+	trimFactor  float64
+	reportLeak  func(stack []byte)
+	budget      *memoryBudget
+
+	idleTimeout     time.Duration
+	janitorInterval time.Duration
+	// now returns the time entries are released at, replaced in tests
+	now         func() time.Time
+	stopJanitor chan struct{}
+	janitorDone chan struct{}
+	closeOnce   sync.Once
+
+	stats poolCounters
+}
+
+// poolEntry is an arena held by the pool.
+type poolEntry struct {
+	item     weak.Pointer[PoolItem]
+	held     *PoolItem // keeps hot and prewarmed items alive
+	size     int       // Cap of the arena when it was released
+	released time.Time // set if the pool has an idle timeout
+	class    int       // index of the bucket holding the entry
+
+	hot       bool
+	prewarmed bool
+
+	prev, next   *poolEntry // neighbours in the bucket
+	older, newer *poolEntry // neighbours by release time
 }
 
-// arenaPoolItemSize is used to track the required memory across the last 50 arenas in the pool
+// arenaPoolItemSize is used to track the required memory across the last 50 arenas in the pool.
+// It is the SizeEstimator of SizingMean.
 type arenaPoolItemSize struct {
 	count      int
 	totalBytes int
 }
 
-// PoolItem wraps an arena.Arena for use in the pool
+// PoolItem wraps an arena.Arena for use in the pool.
+// Acquire returns a new PoolItem every time, which must be released once.
 type PoolItem struct {
 	Arena Arena
 	Key   uint64
+
+	state atomic.Uint32    // itemAcquired or itemPooled
+	leak  *runtime.Cleanup // set while acquired from a pool in debug mode
+
+	charged int // bytes charged to the pool's memory budget while acquired
+}
+
+const (
+	// itemAcquired is the zero value, so that items created outside of a
+	// pool can be released to one.
+	itemAcquired uint32 = iota
+	itemPooled
+)
+
+// EvictionPolicy selects which pooled arena is dropped when releasing an
+// arena exceeds the limits set by WithMaxPoolItems or WithMaxPoolBytes.
+type EvictionPolicy int
+
+const (
+	// EvictLIFO drops the arena being released and keeps the pooled ones.
+	// This is the default.
+	EvictLIFO EvictionPolicy = iota
+	// EvictLargest drops the pooled arena with the largest capacity.
+	EvictLargest
+	// EvictLRU drops the arena that has been pooled the longest.
+	EvictLRU
+)
+
+// PoolOption configures a Pool.
+type PoolOption func(p *Pool)
+
+// WithMaxPoolItems limits the number of arenas retained by the pool.
+// Zero, the default, means no limit.
+func WithMaxPoolItems(n int) PoolOption {
+	return func(p *Pool) {
+		p.maxItems = n
+	}
+}
+
+// WithMaxPoolBytes limits the total capacity of the arenas retained by the
+// pool. Zero, the default, means no limit.
+func WithMaxPoolBytes(n int) PoolOption {
+	return func(p *Pool) {
+		p.maxBytes = n
+	}
+}
+
+// WithEvictionPolicy sets the policy choosing which arena to drop when the
+// pool exceeds its limits. Dropped arenas are released with Arena.Release.
+func WithEvictionPolicy(policy EvictionPolicy) PoolOption {
+	return func(p *Pool) {
+		p.eviction = policy
+	}
+}
+
+// WithArenaFactory sets the function creating new arenas, e.g. to pool
+// concurrent or mmap arenas. sizeHint is the arena size estimated for the
+// key, clamped to the limits set by WithMinArenaSize and WithMaxArenaSize.
+// By default, the pool creates monotonic arenas with a minimum buffer size of
+// sizeHint.
+func WithArenaFactory(factory func(sizeHint int) Arena) PoolOption {
+	return func(p *Pool) {
+		p.newArena = factory
+	}
+}
+
+// WithDefaultArenaSize sets the size hint for keys without a size estimate.
+// It defaults to 1MB.
+func WithDefaultArenaSize(size int) PoolOption {
+	return func(p *Pool) {
+		p.defaultSize = size
+	}
+}
+
+// WithMinArenaSize sets the smallest size hint new arenas are created with,
+// so that keys with little or no peak usage don't create tiny arenas.
+// It defaults to 32KB, the default buffer size of monotonic arenas.
+func WithMinArenaSize(size int) PoolOption {
+	return func(p *Pool) {
+		p.minSize = size
+	}
+}
+
+// WithMaxArenaSize sets the largest size hint new arenas are created with.
+// Zero, the default, means no limit.
+func WithMaxArenaSize(size int) PoolOption {
+	return func(p *Pool) {
+		p.maxSize = size
+	}
+}
+
+// WithTrimFactor makes Release trim arenas whose capacity exceeds factor
+// times the size estimated for their key down to that size before pooling
+// them, so that an arena that once served an unusually large request doesn't
+// stay that large. Only arenas implementing Trimmer are trimmed. Zero, the
+// default, disables trimming.
+func WithTrimFactor(factor float64) PoolOption {
+	return func(p *Pool) {
+		p.trimFactor = factor
+	}
+}
+
+// WithHotSetSize makes the pool hold the n most recently released arenas
+// strongly, so that a GC cycle can't collect all arenas and reuse survives
+// steady GC pressure. The remaining arenas are held weakly as usual.
+// Zero, the default, holds all arenas weakly.
+func WithHotSetSize(n int) PoolOption {
+	return func(p *Pool) {
+		p.hotSize = n
+	}
 }
 
 // NewArenaPool creates a new Pool instance
-func NewArenaPool() *Pool {
-	return &Pool{
-		sizes: make(map[uint64]*arenaPoolItemSize),
+func NewArenaPool(opts ...PoolOption) *Pool {
+	p := &Pool{
+		sizes:  make(map[uint64]*sizeEntry),
+		sizing: SizingPercentile(0.9, defaultSizingWindow),
+		newArena: func(sizeHint int) Arena {
+			return NewMonotonicArena(WithMinBufferSize(sizeHint))
+		},
+		defaultSize: defaultArenaSize,
+		minSize:     minBufferSize,
+		now:         time.Now,
+		gcRan:       new(atomic.Bool),
+	}
+	watchGC(weak.Make(p.gcRan))
+	p.sizeLRU.prev, p.sizeLRU.next = &p.sizeLRU, &p.sizeLRU
+	for _, opt := range opts {
+		opt(p)
 	}
+	p.startJanitor()
+	return p
 }
 
 // Acquire gets an arena from the pool or creates a new one if none are available.
 // The id parameter is used to track arena sizes per use case for optimization.
+// Among the pooled arenas, Acquire picks one of the smallest size class that
+// fits the size estimated for the key, or the largest one if none fits.
 func (p *Pool) Acquire(key uint64) *PoolItem {
+	item, _ := p.acquire(nil, key)
+	return item
+}
+
+// acquire implements Acquire and AcquireContext. It waits for the memory
+// budget unless ctx is nil.
+func (p *Pool) acquire(ctx context.Context, key uint64) (*PoolItem, error) {
 	p.mu.Lock()
-	defer p.mu.Unlock()
+	return p.acquireSized(ctx, key, p.sizeHint(key), nil)
+}
+
+// acquireSized is like acquire for a key whose size hint has been looked up
+// already. It is called with p.mu held and unlocks it. If the pool has no
+// arena, it tries steal, if set, before creating a new one. steal is called
+// with the pool's mutex held.
+func (p *Pool) acquireSized(ctx context.Context, key uint64, sizeHint int, steal func(sizeHint int) *PoolItem) (*PoolItem, error) {
+	p.releaseIdle()
 
 	// Try to find an available arena in the pool
-	for len(p.pool) > 0 {
-		// Pop the last item
-		lastIdx := len(p.pool) - 1
-		wp := p.pool[lastIdx]
-		p.pool = p.pool[:lastIdx]
+	v := p.take(sizeHint)
+	stolen := false
+	if v == nil && steal != nil {
+		v = steal(sizeHint)
+		stolen = v != nil
+	}
 
-		v := wp.Value()
+	charge := sizeHint
+	if p.budget != nil {
+		reserved := false
 		if v != nil {
-			v.Key = key
-			return v
+			// Charge the memory the arena already holds.
+			charge = max(charge, v.Arena.Cap())
+			if ctx != nil && charge > sizeHint {
+				reserved = p.budget.tryReserve(charge)
+				if !reserved {
+					// Don't wait for an arena larger than the key needs,
+					// leave it to a later Acquire and create one charged
+					// only the key's size instead.
+					p.put(v, false)
+					v, stolen, charge = nil, false, sizeHint
+				}
+			}
 		}
-		// If weak pointer was nil (GC collected), continue to next item
+		if ctx == nil {
+			p.budget.charge(charge)
+		} else if !reserved {
+			p.mu.Unlock()
+			err := p.budget.reserve(ctx, charge)
+			p.mu.Lock()
+			if err != nil {
+				// Hand the arena to the next Acquire instead.
+				if v != nil {
+					p.put(v, false)
+				}
+				p.mu.Unlock()
+				return nil, err
+			}
+		}
+	}
+
+	p.stats.acquires++
+	if v != nil {
+		p.stats.hits++
+		if stolen {
+			p.stats.steals++
+		}
+		p.mu.Unlock()
+		// A new handle for every Acquire makes releasing a stale one panic,
+		// as it stays in the pooled state.
+		item := &PoolItem{
+			Arena: v.Arena,
+			Key:   key,
+		}
+		if p.budget != nil {
+			item.charged = charge
+		}
+		p.trackLeak(item)
+		return item, nil
 	}
 
 	// No arena available, create a new one
-	size := WithMinBufferSize(p.getArenaSize(key))
-	return &PoolItem{
-		Arena: NewMonotonicArena(size),
+	p.stats.misses++
+	p.mu.Unlock()
+	item := &PoolItem{
+		Arena: p.newArena(sizeHint),
 		Key:   key,
 	}
+	if p.budget != nil {
+		item.charged = charge
+	}
+	p.trackLeak(item)
+	return item, nil
+}
+
+// take removes the best fitting pooled arena that hasn't been collected from
+// the pool, if any.
+func (p *Pool) take(sizeHint int) *PoolItem {
+	for p.count > 0 {
+		e := p.bestFit(sizeHint)
+		p.remove(e)
+
+		if v := e.item.Value(); v != nil {
+			return v
+		}
+		// If weak pointer was nil (GC collected), continue to next item
+		p.stats.collected++
+	}
+	return nil
 }
 
 // Release returns an arena to the pool for reuse.
-// The peak memory usage is recorded to optimize future arena sizes for this use case.
+// The peak memory usage since the arena was last reset is recorded to optimize
+// future arena sizes for this use case.
+// Release panics if the item has already been released, even if its arena has
+// been acquired again meanwhile: every Acquire hands out a new PoolItem.
 func (p *Pool) Release(item *PoolItem) {
-	peak := item.Arena.Peak()
-	item.Arena.Reset()
+	peak := p.reset(item)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.releaseIdle()
+	p.recordPeak(item.Key, peak)
+	p.trim(item, p.sizeHint(item.Key))
+	p.put(item, false)
+}
 
+func (p *Pool) ReleaseMany(items []*PoolItem) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
-	// Record the peak usage for this use case
-	if size, ok := p.sizes[item.Key]; ok {
-		if size.count == 50 {
-			size.count = 1
-			size.totalBytes = size.totalBytes / 50
+	p.releaseIdle()
+	for _, item := range items {
+		peak := p.reset(item)
+		p.recordPeak(item.Key, peak)
+		p.trim(item, p.sizeHint(item.Key))
+		p.put(item, false)
+	}
+}
+
+// reset marks a released item as pooled and resets its arena. It returns the
+// peak usage of the arena before the reset.
+func (p *Pool) reset(item *PoolItem) int {
+	p.markReleased(item)
+	p.releaseCharge(item)
+	peak := peakOfCycle(item.Arena)
+	item.Arena.Reset()
+	return peak
+}
+
+// markReleased moves an item from the acquired to the pooled state before
+// its arena is reset, so that releasing it twice can't reset an arena that
+// has been handed out again meanwhile.
+func (p *Pool) markReleased(item *PoolItem) {
+	if !item.state.CompareAndSwap(itemAcquired, itemPooled) {
+		panic("arena: PoolItem released twice")
+	}
+	item.untrackLeak()
+}
+
+// recordPeak records the peak usage for a use case.
+func (p *Pool) recordPeak(key uint64, peak int) {
+	size, ok := p.sizes[key]
+	if !ok {
+		size = p.addSizeKey(key)
+	} else {
+		p.sizeLRU.pushFront(size)
+	}
+	size.Observe(peak)
+}
+
+// trim trims a reset item to size, the size estimated for its key, if the
+// trim factor is set and the arena exceeds it.
+func (p *Pool) trim(item *PoolItem, size int) {
+	if p.trimFactor <= 0 {
+		return
+	}
+	t, ok := item.Arena.(Trimmer)
+	if !ok {
+		return
+	}
+	if float64(item.Arena.Cap()) > p.trimFactor*float64(size) {
+		t.Trim(size)
+		p.stats.trims++
+	}
+}
+
+// put adds a reset item back to the pool using a weak pointer and evicts
+// arenas until the pool is within its limits again. Prewarmed items are held
+// strongly until they're acquired.
+func (p *Pool) put(item *PoolItem, prewarmed bool) {
+	item.Key = 0
+
+	size := item.Arena.Cap()
+	if p.maxBytes > 0 && size > p.maxBytes {
+		// Pooling the arena would evict everything else and then the
+		// arena itself.
+		p.stats.evictions++
+		item.Arena.Release()
+		return
+	}
+	e := &poolEntry{
+		item:  weak.Make(item),
+		size:  size,
+		class: max(bits.Len(uint(size))-1, 0),
+	}
+	if p.idleTimeout > 0 {
+		e.released = p.now()
+	}
+	if prewarmed {
+		item.state.Store(itemPooled)
+		e.held, e.prewarmed = item, true
+	}
+	p.push(e)
+	p.addHot(e, item)
+
+	if !p.overLimit() {
+		return
+	}
+	// Arenas collected by the GC still count towards the limits until
+	// they're found, so get rid of them before evicting live ones. That
+	// takes a walk over all entries, which only finds any after a GC.
+	if p.gcRan.Swap(false) {
+		p.dropCollected()
+	}
+	for p.overLimit() {
+		p.evict()
+	}
+}
+
+// push adds an entry to its bucket and as the newest entry.
+func (p *Pool) push(e *poolEntry) {
+	if head := p.buckets[e.class]; head != nil {
+		e.next, head.prev = head, e
+	}
+	p.buckets[e.class] = e
+	p.nonEmpty |= 1 << e.class
+
+	if p.newest != nil {
+		e.older, p.newest.newer = p.newest, e
+	} else {
+		p.oldest = e
+	}
+	p.newest = e
+
+	p.count++
+	p.bytes += e.size
+}
+
+// remove unlinks an entry from the pool.
+func (p *Pool) remove(e *poolEntry) {
+	newer := e.newer
+	if e.prev != nil {
+		e.prev.next = e.next
+	} else {
+		p.buckets[e.class] = e.next
+		if e.next == nil {
+			p.nonEmpty &^= 1 << e.class
 		}
-		size.count++
-		size.totalBytes += peak
+	}
+	if e.next != nil {
+		e.next.prev = e.prev
+	}
+
+	if e.older != nil {
+		e.older.newer = e.newer
+	} else {
+		p.oldest = e.newer
+	}
+	if e.newer != nil {
+		e.newer.older = e.older
 	} else {
-		p.sizes[item.Key] = &arenaPoolItemSize{
-			count:      1,
-			totalBytes: peak,
+		p.newest = e.older
+	}
+
+	e.prev, e.next, e.older, e.newer = nil, nil, nil, nil
+	p.count--
+	p.bytes -= e.size
+
+	if e.hot {
+		p.hot--
+		if p.hotOldest == e {
+			p.hotOldest = newer
 		}
+		p.promoteHot()
 	}
+}
 
-	item.Key = 0
+// addHot adds the newest entry to the hot set, demoting the oldest hot entry
+// if the set is full.
+func (p *Pool) addHot(e *poolEntry, item *PoolItem) {
+	if p.hotSize <= 0 {
+		return
+	}
+	e.hot, e.held = true, item
+	p.hot++
+	if p.hotOldest == nil {
+		p.hotOldest = e
+	}
+	if p.hot > p.hotSize {
+		old := p.hotOldest
+		p.hotOldest = old.newer
+		old.hot = false
+		if !old.prewarmed {
+			old.held = nil
+		}
+		p.hot--
+	}
+}
 
-	// Add the arena back to the pool using a weak pointer
-	w := weak.Make(item)
-	p.pool = append(p.pool, w)
+// promoteHot refills the hot set after an entry left it, with the newest
+// entry that isn't hot if its arena hasn't been collected yet.
+func (p *Pool) promoteHot() {
+	if p.hot >= p.hotSize {
+		return
+	}
+	e := p.newest
+	if p.hotOldest != nil {
+		e = p.hotOldest.older
+	}
+	if e == nil {
+		return
+	}
+	if v := e.item.Value(); v != nil {
+		e.hot, e.held = true, v
+		p.hot++
+		p.hotOldest = e
+	}
 }
 
-func (p *Pool) ReleaseMany(items []*PoolItem) {
-	p.mu.Lock()
-	defer p.mu.Unlock()
+// bestFit returns the most recently released entry of the smallest size
+// class whose arenas are at least size bytes, or of the largest class if
+// there is none. The pool must not be empty.
+func (p *Pool) bestFit(size int) *poolEntry {
+	size = max(size, 1)
+	// Arenas in bucket i have a capacity in [1<<i, 1<<(i+1)), so those in
+	// buckets from ceil(log2(size)) on fit. Only some in the bucket below
+	// do, so only look at its head.
+	if head := p.buckets[bits.Len(uint(size))-1]; head != nil && head.size >= size {
+		return head
+	}
+	fit := bits.Len(uint(size - 1))
+	if above := p.nonEmpty >> fit; above != 0 {
+		return p.buckets[fit+bits.TrailingZeros64(above)]
+	}
+	return p.buckets[bits.Len64(p.nonEmpty)-1]
+}
 
-	for _, item := range items {
+func (p *Pool) overLimit() bool {
+	return (p.maxItems > 0 && p.count > p.maxItems) ||
+		(p.maxBytes > 0 && p.bytes > p.maxBytes)
+}
 
-		peak := item.Arena.Peak()
-		item.Arena.Reset()
+// dropCollected removes the entries whose arena has been collected.
+func (p *Pool) dropCollected() {
+	for e := p.oldest; e != nil; {
+		newer := e.newer
+		if e.item.Value() == nil {
+			p.remove(e)
+			p.stats.collected++
+		}
+		e = newer
+	}
+}
 
-		// Record the peak usage for this use case
-		if size, ok := p.sizes[item.Key]; ok {
-			if size.count == 50 {
-				size.count = 1
-				size.totalBytes = size.totalBytes / 50
-			}
-			size.count++
-			size.totalBytes += peak
-		} else {
-			p.sizes[item.Key] = &arenaPoolItemSize{
-				count:      1,
-				totalBytes: peak,
-			}
+// watchGC sets ran after the next GC cycle and keeps doing so after every
+// following one, for as long as ran is reachable.
+func watchGC(ran weak.Pointer[atomic.Bool]) {
+	// Large enough not to be batched with other objects by the tiny
+	// allocator, which would delay the cleanup.
+	sentinel := new([32]byte)
+	runtime.AddCleanup(sentinel, func(ran weak.Pointer[atomic.Bool]) {
+		if r := ran.Value(); r != nil {
+			r.Store(true)
+			watchGC(ran)
 		}
+	}, ran)
+}
 
-		item.Key = 0
+// evict removes one entry according to the eviction policy and releases its
+// arena.
+func (p *Pool) evict() {
+	e := p.newest
+	switch p.eviction {
+	case EvictLargest:
+		e = p.buckets[bits.Len64(p.nonEmpty)-1]
+		for c := e.next; c != nil; c = c.next {
+			if c.size > e.size {
+				e = c
+			}
+		}
+	case EvictLRU:
+		e = p.oldest
+	}
+	p.remove(e)
+	p.stats.evictions++
 
-		// Add the arena back to the pool using a weak pointer
-		w := weak.Make(item)
-		p.pool = append(p.pool, w)
+	if v := e.item.Value(); v != nil {
+		v.Arena.Release()
 	}
 }
 
 // getArenaSize returns the optimal arena size for a given use case ID.
-// If no size is recorded, it defaults to 1MB.
+// If no size is recorded, or the key has been evicted from the size table,
+// it returns the default size.
 func (p *Pool) getArenaSize(id uint64) int {
 	if size, ok := p.sizes[id]; ok {
-		return size.totalBytes / size.count
+		p.sizeLRU.pushFront(size)
+		return size.Estimate()
+	}
+	return p.defaultSize
+}
+
+// sizeHint returns the size new arenas for a key are created with.
+func (p *Pool) sizeHint(key uint64) int {
+	return p.clampSize(p.getArenaSize(key))
+}
+
+// clampSize clamps a size to the minimum and maximum arena size.
+func (p *Pool) clampSize(size int) int {
+	size = max(size, p.minSize)
+	if p.maxSize > 0 {
+		size = min(size, p.maxSize)
 	}
-	return 1024 * 1024 // Default 1MB
+	return size
 }
@@ -0,0 +1,132 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package arena
+
+import (
+	"context"
+	"sync"
+)
+
+// memoryBudget bounds the bytes charged for the arenas acquired from one or
+// more pools.
+type memoryBudget struct {
+	mu    sync.Mutex
+	limit int
+	used  int
+	// grown sums how much released arenas had outgrown their charge.
+	grown uint64
+	// freed is closed and replaced whenever bytes are returned, waking up
+	// the goroutines waiting for budget.
+	freed chan struct{}
+}
+
+// WithMemoryBudget limits the total bytes charged for arenas that are
+// acquired and not yet released, so that a burst of large requests can't
+// exhaust memory. Acquiring a new arena charges the size estimated for its
+// key, and acquiring a pooled one its capacity if that's larger. Releasing an
+// arena returns the charge and reports how much the arena grew beyond it,
+// see PoolStats.BudgetGrowth. A pooled arena that grew is charged its full
+// capacity when it's acquired again, but growth between Acquire and Release
+// isn't bounded by the budget.
+//
+// AcquireContext waits until the charge fits into the budget, while Acquire
+// charges it right away, even if that exceeds the budget. A single charge
+// larger than the whole budget is admitted once nothing else is charged. If
+// the pooled arena AcquireContext picked is too large to fit right away, it
+// leaves it in the pool and creates a new arena instead, charging only the
+// size estimated for the key.
+//
+// The budget is shared by all pools the option is passed to, e.g. the shards
+// of a ShardedPool. Zero, the default, means no budget.
+func WithMemoryBudget(bytes int) PoolOption {
+	var b *memoryBudget
+	if bytes > 0 {
+		b = &memoryBudget{limit: bytes, freed: make(chan struct{})}
+	}
+	return func(p *Pool) {
+		p.budget = b
+	}
+}
+
+// reserve charges n bytes, waiting until they fit into the budget or ctx is
+// done.
+func (b *memoryBudget) reserve(ctx context.Context, n int) error {
+	for {
+		b.mu.Lock()
+		if b.fits(n) {
+			b.used += n
+			b.mu.Unlock()
+			return nil
+		}
+		freed := b.freed
+		b.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-freed:
+		}
+	}
+}
+
+// tryReserve charges n bytes if they fit into the budget right away.
+func (b *memoryBudget) tryReserve(n int) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.fits(n) {
+		return false
+	}
+	b.used += n
+	return true
+}
+
+// fits reports whether n more bytes fit into the budget. It is called with
+// b.mu held.
+func (b *memoryBudget) fits(n int) bool {
+	return b.used == 0 || b.used+n <= b.limit
+}
+
+// charge charges n bytes without waiting.
+func (b *memoryBudget) charge(n int) {
+	b.mu.Lock()
+	b.used += n
+	b.mu.Unlock()
+}
+
+// release returns n charged bytes for an arena whose capacity is now
+// capacity bytes.
+func (b *memoryBudget) release(n, capacity int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if capacity > n {
+		b.grown += uint64(capacity - n)
+	}
+	if n == 0 {
+		return
+	}
+	b.used -= n
+	close(b.freed)
+	b.freed = make(chan struct{})
+}
+
+func (b *memoryBudget) stats() (inUse int, grown uint64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.used, b.grown
+}
+
+// AcquireContext is like Acquire, but waits until the arena's charge fits
+// into the memory budget set by WithMemoryBudget. It returns ctx's error if
+// ctx is done before. Without a budget, it never waits.
+func (p *Pool) AcquireContext(ctx context.Context, key uint64) (*PoolItem, error) {
+	return p.acquire(ctx, key)
+}
+
+// releaseCharge returns the budget charged for a released item, before its
+// arena is trimmed.
+func (p *Pool) releaseCharge(item *PoolItem) {
+	if p.budget != nil {
+		p.budget.release(item.charged, item.Arena.Cap())
+	}
+	item.charged = 0
+}
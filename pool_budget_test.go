@@ -0,0 +1,177 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package arena
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestArenaPool_MemoryBudget(t *testing.T) {
+	pool := NewArenaPool(WithMemoryBudget(200<<10), WithDefaultArenaSize(100<<10))
+
+	a := pool.Acquire(1)
+	b := pool.Acquire(2)
+	assert.Equal(t, 200<<10, pool.Stats().BudgetInUse)
+
+	// Acquire doesn't wait for the budget.
+	c := pool.Acquire(3)
+	assert.Equal(t, 300<<10, pool.Stats().BudgetInUse)
+	c.Arena.Alloc(100<<10, 1)
+	pool.Release(c)
+	assert.Equal(t, 200<<10, pool.Stats().BudgetInUse)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := pool.AcquireContext(ctx, 3)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, 200<<10, pool.Stats().BudgetInUse)
+	assert.Equal(t, uint64(3), pool.Stats().Acquires)
+
+	acquired := make(chan *PoolItem)
+	go func() {
+		item, err := pool.AcquireContext(context.Background(), 3)
+		assert.NoError(t, err)
+		acquired <- item
+	}()
+	select {
+	case <-acquired:
+		t.Fatal("acquired beyond the budget")
+	case <-time.After(10 * time.Millisecond):
+	}
+	pool.Release(a)
+	c = <-acquired
+	assert.Equal(t, 200<<10, pool.Stats().BudgetInUse)
+
+	pool.ReleaseMany([]*PoolItem{b, c})
+	assert.Equal(t, 0, pool.Stats().BudgetInUse)
+}
+
+func TestArenaPool_MemoryBudgetOversized(t *testing.T) {
+	pool := NewArenaPool(WithMemoryBudget(64<<10), WithDefaultArenaSize(100<<10))
+
+	// A charge larger than the budget is admitted when nothing else is
+	// charged, so it can't block forever.
+	item, err := pool.AcquireContext(context.Background(), 1)
+	require.NoError(t, err)
+	assert.Equal(t, 100<<10, pool.Stats().BudgetInUse)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = pool.AcquireContext(ctx, 2)
+	require.ErrorIs(t, err, context.Canceled)
+
+	pool.Release(item)
+	assert.Equal(t, 0, pool.Stats().BudgetInUse)
+}
+
+func TestArenaPool_MemoryBudgetChargesCap(t *testing.T) {
+	pool := NewArenaPool(WithMemoryBudget(256<<10), WithDefaultArenaSize(64<<10))
+
+	item := pool.Acquire(1)
+	item.Arena.Alloc(1<<20, 1)
+	grown := item.Arena.Cap()
+	require.Greater(t, grown, 1<<20)
+	pool.Release(item)
+	stats := pool.Stats()
+	assert.Equal(t, 0, stats.BudgetInUse)
+	assert.Equal(t, uint64(grown-64<<10), stats.BudgetGrowth)
+
+	// The grown arena is charged its capacity, which exceeds the budget, but
+	// is admitted as nothing else is charged...
+	item, err := pool.AcquireContext(context.Background(), 2)
+	require.NoError(t, err)
+	assert.Equal(t, grown, item.Arena.Cap())
+	assert.Equal(t, grown, pool.Stats().BudgetInUse)
+
+	// ...and blocks later acquires until it's released.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = pool.AcquireContext(ctx, 3)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	pool.Release(item)
+	assert.Equal(t, 0, pool.Stats().BudgetInUse)
+	assert.Equal(t, uint64(grown-64<<10), pool.Stats().BudgetGrowth)
+}
+
+func TestArenaPool_MemoryBudgetSkipsOversizedPooled(t *testing.T) {
+	pool := NewArenaPool(WithMemoryBudget(256<<10), WithDefaultArenaSize(64<<10))
+
+	item := pool.Acquire(1)
+	item.Arena.Alloc(8<<20, 1)
+	grown := item.Arena
+	small := pool.Acquire(2)
+	pool.Release(item)
+	require.Equal(t, 64<<10, pool.Stats().BudgetInUse)
+
+	// The only pooled arena is the 8MB one, which doesn't fit into the
+	// budget while small is acquired, so it stays in the pool and a new
+	// arena is created for the key's estimate instead of waiting.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	fresh, err := pool.AcquireContext(ctx, 3)
+	require.NoError(t, err)
+	assert.NotSame(t, grown, fresh.Arena)
+	assert.Equal(t, 64<<10, fresh.Arena.Cap())
+	assert.Equal(t, 128<<10, pool.Stats().BudgetInUse)
+	stats := pool.Stats()
+	assert.Equal(t, uint64(3), stats.Acquires)
+	assert.Equal(t, uint64(3), stats.Misses)
+	require.Len(t, pooledItems(pool), 1)
+	require.Same(t, grown, pooledItems(pool)[0].Arena)
+
+	// Once it fits, the key that needs it gets it.
+	pool.ReleaseMany([]*PoolItem{small, fresh})
+	item, err = pool.AcquireContext(context.Background(), 1)
+	require.NoError(t, err)
+	assert.Same(t, grown, item.Arena)
+	assert.Equal(t, grown.Cap(), pool.Stats().BudgetInUse)
+	pool.Release(item)
+}
+
+func TestArenaPool_DoMemoryBudget(t *testing.T) {
+	pool := NewArenaPool(WithMemoryBudget(100<<10), WithDefaultArenaSize(100<<10))
+	item := pool.Acquire(1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err := pool.Do(ctx, 2, func(ctx context.Context, a Arena) error {
+		t.Fatal("called beyond the budget")
+		return nil
+	})
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	pool.Release(item)
+	err = pool.Do(context.Background(), 2, func(ctx context.Context, a Arena) error {
+		assert.Equal(t, 100<<10, pool.Stats().BudgetInUse)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 0, pool.Stats().BudgetInUse)
+}
+
+func TestShardedPool_MemoryBudget(t *testing.T) {
+	pool := NewShardedArenaPool(4, WithMemoryBudget(100<<10), WithDefaultArenaSize(100<<10))
+
+	item, err := pool.acquire(nil, 0, 1)
+	require.NoError(t, err)
+	assert.Equal(t, 100<<10, pool.Stats().BudgetInUse)
+
+	// The shards share the budget.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = pool.acquire(ctx, 1, 2)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	pool.release(0, item)
+	item, err = pool.acquire(context.Background(), 1, 2)
+	require.NoError(t, err)
+	assert.Equal(t, 100<<10, pool.Stats().BudgetInUse)
+	pool.Release(item)
+	assert.Equal(t, 0, pool.Stats().BudgetInUse)
+}
@@ -0,0 +1,47 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package arena
+
+import (
+	"log"
+	"runtime"
+	"runtime/debug"
+)
+
+// WithDebugMode makes the pool report leaked PoolItems at some cost: Acquire
+// records the caller's stack, and reportLeak is called with it when an item
+// is garbage collected without having been released. If reportLeak is nil,
+// leaks are logged with the standard logger.
+//
+// It is meant for tests and debugging.
+func WithDebugMode(reportLeak func(stack []byte)) PoolOption {
+	if reportLeak == nil {
+		reportLeak = func(stack []byte) {
+			log.Printf("arena: PoolItem was never released, acquired at:\n%s", stack)
+		}
+	}
+	return func(p *Pool) {
+		p.reportLeak = reportLeak
+	}
+}
+
+// trackLeak arranges for the acquired item to be reported if it is collected
+// before being released.
+func (p *Pool) trackLeak(item *PoolItem) {
+	if p.reportLeak == nil {
+		return
+	}
+	report := p.reportLeak
+	cleanup := runtime.AddCleanup(item, func(stack []byte) {
+		report(stack)
+	}, debug.Stack())
+	item.leak = &cleanup
+}
+
+// untrackLeak cancels the leak report of a released item.
+func (i *PoolItem) untrackLeak() {
+	if i.leak != nil {
+		i.leak.Stop()
+		i.leak = nil
+	}
+}
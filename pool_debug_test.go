@@ -0,0 +1,89 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package arena
+
+import (
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestArenaPool_DoubleRelease(t *testing.T) {
+	pool := NewArenaPool()
+
+	item := pool.Acquire(1)
+	pool.Release(item)
+	require.PanicsWithValue(t, "arena: PoolItem released twice", func() {
+		pool.Release(item)
+	})
+	require.Equal(t, 1, pool.count)
+
+	// Acquiring the arena again hands out a new item, which can be
+	// released once.
+	again := pool.Acquire(1)
+	require.Same(t, item.Arena, again.Arena)
+	pool.ReleaseMany([]*PoolItem{again})
+	require.PanicsWithValue(t, "arena: PoolItem released twice", func() {
+		pool.ReleaseMany([]*PoolItem{again})
+	})
+
+	// Prewarmed items start out pooled.
+	pool = NewArenaPool()
+	pool.Prewarm(1, 0)
+	require.Panics(t, func() { pool.Release(pooledItems(pool)[0]) })
+
+	// Items created outside of the pool can be released to it.
+	pool.Release(&PoolItem{Arena: NewMonotonicArena()})
+	require.Equal(t, 2, pool.count)
+}
+
+func TestArenaPool_StaleRelease(t *testing.T) {
+	pool := NewArenaPool()
+
+	item := pool.Acquire(1)
+	pool.Release(item)
+	reused := pool.Acquire(2)
+	reused.Arena.Alloc(8, 1)
+
+	// Releasing the stale item must not reset the arena now in use.
+	require.NotSame(t, item, reused)
+	require.Same(t, item.Arena, reused.Arena)
+	require.PanicsWithValue(t, "arena: PoolItem released twice", func() {
+		pool.Release(item)
+	})
+	require.Equal(t, 8, reused.Arena.Len())
+	pool.Release(reused)
+	require.Equal(t, 1, pool.count)
+}
+
+func TestArenaPool_LeakDetection(t *testing.T) {
+	leaks := make(chan []byte, 2)
+	pool := NewArenaPool(WithDebugMode(func(stack []byte) {
+		leaks <- stack
+	}))
+
+	released := pool.Acquire(1)
+	pool.Release(released)
+	func() {
+		pool.Acquire(2) // leaked
+	}()
+	// Also drop the pooled item, which must not be reported.
+	released = nil
+	runtime.GC()
+	runtime.GC()
+
+	select {
+	case stack := <-leaks:
+		assert.Contains(t, string(stack), "TestArenaPool_LeakDetection")
+	case <-time.After(5 * time.Second):
+		t.Fatal("leaked item wasn't reported")
+	}
+	select {
+	case <-leaks:
+		t.Fatal("released item was reported")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
@@ -0,0 +1,55 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package arena
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+)
+
+// Do acquires an arena for key, injects it into ctx with InjectContextArena
+// and calls fn with both. The arena is released back to the pool when fn
+// returns, or panics, so fn must not retain it or anything allocated from it.
+// Do returns fn's error, or ctx's error without calling fn if ctx is already
+// done or gets done while waiting for the memory budget, see WithMemoryBudget.
+func (p *Pool) Do(ctx context.Context, key uint64, fn func(ctx context.Context, a Arena) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	item, err := p.AcquireContext(ctx, key)
+	if err != nil {
+		return err
+	}
+	defer p.Release(item)
+	return fn(InjectContextArena(ctx, item.Arena), item.Arena)
+}
+
+// DoRecover is like Do, but recovers a panic in fn and returns it as a
+// *PanicError.
+func (p *Pool) DoRecover(ctx context.Context, key uint64, fn func(ctx context.Context, a Arena) error) (err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			err = &PanicError{Value: v, Stack: debug.Stack()}
+		}
+	}()
+	return p.Do(ctx, key, fn)
+}
+
+// PanicError is returned by Pool.DoRecover when the function panicked.
+type PanicError struct {
+	// Value is the value passed to panic.
+	Value any
+	// Stack is the stack trace of the panicking goroutine.
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("arena: recovered panic: %v", e.Value)
+}
+
+// Unwrap returns the panic value if it is an error.
+func (e *PanicError) Unwrap() error {
+	err, _ := e.Value.(error)
+	return err
+}
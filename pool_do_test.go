@@ -0,0 +1,85 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package arena
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestArenaPool_Do(t *testing.T) {
+	pool := NewArenaPool()
+
+	var used Arena
+	err := pool.Do(context.Background(), 1, func(ctx context.Context, a Arena) error {
+		require.Same(t, a, ExtractContextArena(ctx))
+		a.Alloc(100, 1)
+		used = a
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 0, used.Len())
+	assert.Equal(t, 1, pool.count)
+	assert.Equal(t, 100, pool.getArenaSize(1))
+
+	errFailed := errors.New("failed")
+	err = pool.Do(context.Background(), 1, func(ctx context.Context, a Arena) error {
+		require.Same(t, used, a)
+		return errFailed
+	})
+	require.ErrorIs(t, err, errFailed)
+	assert.Equal(t, 1, pool.count)
+}
+
+func TestArenaPool_DoContextDone(t *testing.T) {
+	pool := NewArenaPool()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := pool.Do(ctx, 1, func(ctx context.Context, a Arena) error {
+		t.Fatal("fn called with a canceled context")
+		return nil
+	})
+	require.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, uint64(0), pool.Stats().Acquires)
+}
+
+func TestArenaPool_DoReleasesOnPanic(t *testing.T) {
+	pool := NewArenaPool()
+
+	require.PanicsWithValue(t, "boom", func() {
+		_ = pool.Do(context.Background(), 1, func(ctx context.Context, a Arena) error {
+			panic("boom")
+		})
+	})
+	assert.Equal(t, 1, pool.count)
+}
+
+func TestArenaPool_DoRecover(t *testing.T) {
+	pool := NewArenaPool()
+
+	err := pool.DoRecover(context.Background(), 1, func(ctx context.Context, a Arena) error {
+		panic("boom")
+	})
+	var panicErr *PanicError
+	require.ErrorAs(t, err, &panicErr)
+	assert.Equal(t, "boom", panicErr.Value)
+	assert.Contains(t, string(panicErr.Stack), "TestArenaPool_DoRecover")
+	assert.EqualError(t, err, "arena: recovered panic: boom")
+	assert.Equal(t, 1, pool.count)
+
+	errFailed := errors.New("failed")
+	err = pool.DoRecover(context.Background(), 1, func(ctx context.Context, a Arena) error {
+		panic(errFailed)
+	})
+	require.ErrorIs(t, err, errFailed)
+
+	err = pool.DoRecover(context.Background(), 1, func(ctx context.Context, a Arena) error {
+		return errFailed
+	})
+	require.Same(t, errFailed, err)
+}
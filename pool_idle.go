@@ -0,0 +1,84 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package arena
+
+import (
+	"time"
+)
+
+// WithIdleTimeout makes the pool release pooled arenas with Arena.Release
+// once they have been idle for longer than d, so that a pool doesn't keep
+// large arenas alive through quiet periods in which the GC rarely runs.
+// Idle arenas are released by Acquire and Release, or in the background with
+// WithIdleJanitor. Zero, the default, keeps arenas until they're collected or
+// evicted.
+func WithIdleTimeout(d time.Duration) PoolOption {
+	return func(p *Pool) {
+		p.idleTimeout = d
+	}
+}
+
+// WithIdleJanitor starts a goroutine that releases idle arenas, see
+// WithIdleTimeout, every interval, so that they're released even if the pool
+// isn't used anymore. The goroutine keeps the pool alive until Pool.Close
+// stops it. It has no effect without an idle timeout.
+func WithIdleJanitor(interval time.Duration) PoolOption {
+	return func(p *Pool) {
+		p.janitorInterval = interval
+	}
+}
+
+// startJanitor starts the goroutine configured by WithIdleJanitor.
+func (p *Pool) startJanitor() {
+	if p.idleTimeout <= 0 || p.janitorInterval <= 0 {
+		return
+	}
+	p.stopJanitor = make(chan struct{})
+	p.janitorDone = make(chan struct{})
+	go func() {
+		defer close(p.janitorDone)
+		ticker := time.NewTicker(p.janitorInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-p.stopJanitor:
+				return
+			case <-ticker.C:
+				p.mu.Lock()
+				p.releaseIdle()
+				p.mu.Unlock()
+			}
+		}
+	}()
+}
+
+// Close stops the goroutine started by WithIdleJanitor and waits for it to
+// exit. The pool remains usable. Close is safe to call more than once.
+func (p *Pool) Close() {
+	if p.stopJanitor == nil {
+		return
+	}
+	p.closeOnce.Do(func() {
+		close(p.stopJanitor)
+	})
+	<-p.janitorDone
+}
+
+// releaseIdle removes the entries idle for longer than the idle timeout and
+// releases their arenas. Entries are linked by release time, so it only
+// needs to look at the oldest ones.
+func (p *Pool) releaseIdle() {
+	if p.idleTimeout <= 0 || p.oldest == nil {
+		return
+	}
+	now := p.now()
+	for e := p.oldest; e != nil && now.Sub(e.released) > p.idleTimeout; e = p.oldest {
+		p.remove(e)
+		if v := e.item.Value(); v != nil {
+			v.Arena.Release()
+			p.stats.idleEvictions++
+		} else {
+			p.stats.collected++
+		}
+	}
+}
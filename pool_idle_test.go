@@ -0,0 +1,142 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package arena
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeClock is a clock for Pool.now that only moves when advanced.
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Unix(1700000000, 0)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func withClock(c *fakeClock) PoolOption {
+	return func(p *Pool) {
+		p.now = c.Now
+	}
+}
+
+func TestArenaPool_IdleTimeout(t *testing.T) {
+	clock := newFakeClock()
+	pool := NewArenaPool(WithIdleTimeout(time.Minute), WithHotSetSize(4), withClock(clock))
+
+	items := []*PoolItem{pool.Acquire(1), pool.Acquire(2), pool.Acquire(3)}
+	for _, item := range items {
+		item.Arena.Alloc(100, 1)
+	}
+	pool.Release(items[0])
+	clock.Advance(30 * time.Second)
+	pool.Release(items[1])
+	clock.Advance(31 * time.Second)
+
+	// Releasing sweeps the first arena, idle for 61s, before pooling the
+	// third one.
+	pool.Release(items[2])
+	assert.True(t, isReleased(items[0]))
+	assert.Equal(t, 2, pool.count)
+	assert.Equal(t, 2, pool.hot)
+	assert.Equal(t, uint64(1), pool.Stats().IdleEvictions)
+
+	// Acquiring sweeps the second one, idle for 61s as well.
+	clock.Advance(30 * time.Second)
+	item := pool.Acquire(1)
+	assert.Same(t, items[2].Arena, item.Arena)
+	assert.True(t, isReleased(items[1]))
+	assert.Equal(t, 0, pool.count)
+
+	stats := pool.Stats()
+	assert.Equal(t, uint64(2), stats.IdleEvictions)
+	assert.Equal(t, uint64(1), stats.Hits)
+	assert.Equal(t, uint64(0), stats.Evictions)
+}
+
+func TestArenaPool_IdleTimeoutDisabled(t *testing.T) {
+	clock := newFakeClock()
+	pool := NewArenaPool(WithHotSetSize(1), withClock(clock))
+
+	item := pool.Acquire(1)
+	pool.Release(item)
+	clock.Advance(24 * time.Hour)
+	assert.Same(t, item.Arena, pool.Acquire(1).Arena)
+	assert.Equal(t, uint64(0), pool.Stats().IdleEvictions)
+
+	// Close is a no-op without a janitor.
+	pool.Close()
+}
+
+func TestArenaPool_IdleJanitor(t *testing.T) {
+	clock := newFakeClock()
+	pool := NewArenaPool(
+		WithIdleTimeout(time.Minute),
+		WithIdleJanitor(time.Millisecond),
+		WithHotSetSize(1),
+		withClock(clock),
+	)
+
+	item := pool.Acquire(1)
+	item.Arena.Alloc(100, 1)
+	pool.Release(item)
+	clock.Advance(2 * time.Minute)
+
+	require.Eventually(t, func() bool {
+		return pool.Stats().IdleEvictions == 1
+	}, time.Second, time.Millisecond)
+	assert.True(t, isReleased(item))
+	assert.Equal(t, 0, pool.Stats().Pooled)
+
+	pool.Close()
+	pool.Close()
+
+	// The pool is still usable, but nothing is swept in the background.
+	item = pool.Acquire(1)
+	pool.Release(item)
+	clock.Advance(2 * time.Minute)
+	time.Sleep(10 * time.Millisecond)
+	assert.Equal(t, 1, pool.Stats().Pooled)
+}
+
+func TestShardedPool_IdleJanitor(t *testing.T) {
+	clock := newFakeClock()
+	pool := NewShardedArenaPool(4,
+		WithIdleTimeout(time.Minute),
+		WithIdleJanitor(time.Millisecond),
+		WithHotSetSize(1),
+		withClock(clock),
+	)
+	defer pool.Close()
+
+	items := make([]*PoolItem, 8)
+	for i := range items {
+		items[i] = pool.Acquire(uint64(i))
+	}
+	pool.ReleaseMany(items)
+	clock.Advance(2 * time.Minute)
+
+	require.Eventually(t, func() bool {
+		return pool.Stats().IdleEvictions == 8
+	}, time.Second, time.Millisecond)
+	assert.Equal(t, 0, pool.Stats().Pooled)
+}
@@ -0,0 +1,53 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package arena
+
+// prewarmer is implemented by arenas that can allocate their buffers ahead of
+// the first Alloc.
+type prewarmer interface {
+	// prewarm allocates the memory of all buffers and touches their pages,
+	// without affecting Len or Peak.
+	prewarm()
+}
+
+// Prewarm adds n arenas of sizeHint bytes to the pool, clamped like the
+// sizes of new arenas, so that the first requests after startup don't pay
+// for creating them. Their buffers are allocated and their pages touched
+// upfront, and the pool holds them strongly until they're acquired, so the
+// GC doesn't collect them before. It does nothing if n isn't positive.
+func (p *Pool) Prewarm(n int, sizeHint int) {
+	p.mu.Lock()
+	sizeHint = p.clampSize(sizeHint)
+	p.mu.Unlock()
+	p.prewarm(n, sizeHint)
+}
+
+// PrewarmKey is like Prewarm, but sizes the arenas by the estimate for key,
+// e.g. after ImportStats.
+func (p *Pool) PrewarmKey(key uint64, n int) {
+	p.mu.Lock()
+	sizeHint := p.sizeHint(key)
+	p.mu.Unlock()
+	p.prewarm(n, sizeHint)
+}
+
+func (p *Pool) prewarm(n int, sizeHint int) {
+	if n <= 0 {
+		return
+	}
+	items := make([]*PoolItem, n)
+	for i := range items {
+		a := p.newArena(sizeHint)
+		if pw, ok := a.(prewarmer); ok {
+			pw.prewarm()
+		}
+		items[i] = &PoolItem{Arena: a}
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, item := range items {
+		p.put(item, true)
+	}
+	p.stats.prewarmed += uint64(n)
+}
@@ -0,0 +1,77 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package arena
+
+import (
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMonotonicArenaPrewarm(t *testing.T) {
+	arena := NewMonotonicArena(WithInitialBufferCount(2))
+	m := arena.(*monotonicArena)
+	arena.(prewarmer).prewarm()
+
+	for _, s := range m.buffers {
+		require.NotNil(t, s.ptr)
+	}
+	assert.Equal(t, 0, arena.Len())
+	assert.Equal(t, 0, arena.Peak())
+	assert.Equal(t, 2*minBufferSize, arena.Cap())
+
+	ptr := m.buffers[0].ptr
+	require.Equal(t, ptr, arena.Alloc(8, 1))
+}
+
+func TestArenaPool_Prewarm(t *testing.T) {
+	pool := NewArenaPool()
+	pool.Prewarm(3, 64*1024)
+	assert.Equal(t, 3, pool.count)
+	assert.Equal(t, 3*64*1024, pool.bytes)
+	assert.Equal(t, uint64(3), pool.Stats().Prewarmed)
+
+	// Prewarmed arenas survive GC until they're acquired.
+	runtime.GC()
+	runtime.GC()
+	require.Len(t, pooledItems(pool), 3)
+
+	item := pool.Acquire(1)
+	assert.Equal(t, uint64(1), pool.Stats().Hits)
+	assert.NotNil(t, item.Arena.(*monotonicArena).buffers[0].ptr)
+	assert.Equal(t, 64*1024, item.Arena.Cap())
+
+	// Once released, they're held weakly like any other arena.
+	pool.Release(item)
+	for _, e := range pooledEntries(pool) {
+		assert.Equal(t, e.item.Value() != item, e.held != nil)
+	}
+
+	// Sizes are clamped.
+	pool.Prewarm(1, 0)
+	assert.Equal(t, 3*64*1024+minBufferSize, pool.bytes)
+}
+
+func TestArenaPool_PrewarmNonPositive(t *testing.T) {
+	pool := NewArenaPool()
+	pool.Prewarm(0, 64*1024)
+	pool.Prewarm(-1, 64*1024)
+	pool.PrewarmKey(1, -1)
+	assert.Equal(t, 0, pool.count)
+	assert.Equal(t, uint64(0), pool.Stats().Prewarmed)
+}
+
+func TestArenaPool_PrewarmKey(t *testing.T) {
+	pool := NewArenaPool()
+	item := pool.Acquire(7)
+	item.Arena.Alloc(100*1024, 1)
+	pool.Release(item)
+	pool.Acquire(0)
+
+	pool.PrewarmKey(7, 2)
+	pool.PrewarmKey(8, 1)
+	assert.Equal(t, 2*100*1024+defaultArenaSize, pool.bytes)
+	assert.Equal(t, 100*1024, pool.Acquire(7).Arena.Cap())
+}
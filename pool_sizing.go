@@ -0,0 +1,239 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package arena
+
+import (
+	"math"
+	"slices"
+)
+
+// SizeEstimator estimates the arena size a Pool key needs from the peak
+// usage of the arenas released for it.
+type SizeEstimator interface {
+	// Observe records the peak usage of a released arena.
+	Observe(peak int)
+	// Estimate returns the size new arenas for the key are created with.
+	Estimate() int
+}
+
+// cyclePeaker is implemented by arenas that track their peak usage since the
+// last Reset or Release apart from the lifetime peak reported by Peak.
+type cyclePeaker interface {
+	cyclePeak() int
+}
+
+// peakOfCycle returns the peak usage of a since it was last reset, so that a
+// single outlier doesn't inflate the sizes recorded for every later use of a
+// pooled arena. Arenas that don't track it report their lifetime peak.
+func peakOfCycle(a Arena) int {
+	if c, ok := a.(cyclePeaker); ok {
+		return c.cyclePeak()
+	}
+	return a.Peak()
+}
+
+// SizingStrategy creates the SizeEstimator for a key the pool hasn't seen
+// before. It is called with the pool's mutex held.
+type SizingStrategy func() SizeEstimator
+
+// defaultSizingWindow is the number of samples the windowed strategies keep
+// by default.
+const defaultSizingWindow = 64
+
+// defaultArenaSize is the default of WithDefaultArenaSize.
+const defaultArenaSize = 1024 * 1024
+
+// WithSizingStrategy sets how the pool sizes new arenas for a key.
+// It defaults to SizingPercentile(0.9, 64), so that nine out of ten arenas
+// fit into their first buffer even if peaks vary from request to request.
+func WithSizingStrategy(strategy SizingStrategy) PoolOption {
+	return func(p *Pool) {
+		p.sizing = strategy
+	}
+}
+
+// WithMaxSizeKeys bounds the number of keys the pool keeps size estimates
+// for. When a new key exceeds the bound, the least recently used key is
+// dropped, and arenas for it get the default size until it has been seen
+// again. Zero, the default, means no limit.
+func WithMaxSizeKeys(n int) PoolOption {
+	return func(p *Pool) {
+		p.maxSizeKeys = n
+	}
+}
+
+// sizeEntry is the size estimate of a key, linked into the pool's LRU list
+// of keys.
+type sizeEntry struct {
+	SizeEstimator
+	key        uint64
+	prev, next *sizeEntry
+}
+
+// pushFront moves e to the front of the list headed by l.
+func (l *sizeEntry) pushFront(e *sizeEntry) {
+	if e.prev != nil {
+		e.unlink()
+	}
+	e.prev, e.next = l, l.next
+	l.next.prev = e
+	l.next = e
+}
+
+func (e *sizeEntry) unlink() {
+	e.prev.next, e.next.prev = e.next, e.prev
+	e.prev, e.next = nil, nil
+}
+
+// addSizeKey creates the size estimate for a new key, dropping the least
+// recently used key if the table is full.
+func (p *Pool) addSizeKey(key uint64) *sizeEntry {
+	if p.maxSizeKeys > 0 && len(p.sizes) >= p.maxSizeKeys {
+		lru := p.sizeLRU.prev
+		lru.unlink()
+		delete(p.sizes, lru.key)
+		p.stats.sizeKeyEvictions++
+	}
+	e := &sizeEntry{SizeEstimator: p.sizing(), key: key}
+	p.sizes[key] = e
+	p.sizeLRU.pushFront(e)
+	return e
+}
+
+// SizingMean sizes arenas by the mean peak. Every 50 samples the mean is
+// collapsed into a single sample, so older peaks fade out over time.
+func SizingMean() SizingStrategy {
+	return func() SizeEstimator {
+		return &arenaPoolItemSize{}
+	}
+}
+
+// Observe satisfies the SizeEstimator interface.
+func (s *arenaPoolItemSize) Observe(peak int) {
+	if s.count == 50 {
+		s.count = 1
+		s.totalBytes = s.totalBytes / 50
+	}
+	s.count++
+	s.totalBytes += peak
+}
+
+// Estimate satisfies the SizeEstimator interface.
+func (s *arenaPoolItemSize) Estimate() int {
+	return s.totalBytes / s.count
+}
+
+// SizingEWMA sizes arenas by an exponentially weighted moving average of the
+// peaks, in which each new peak has the weight alpha, between 0 and 1.
+func SizingEWMA(alpha float64) SizingStrategy {
+	if !(alpha > 0 && alpha <= 1) {
+		panic("arena: EWMA weight must be in (0, 1]")
+	}
+	return func() SizeEstimator {
+		return &ewmaSize{alpha: alpha}
+	}
+}
+
+type ewmaSize struct {
+	alpha   float64
+	average float64
+	seen    bool
+}
+
+// Observe satisfies the SizeEstimator interface.
+func (s *ewmaSize) Observe(peak int) {
+	if !s.seen {
+		s.average = float64(peak)
+		s.seen = true
+		return
+	}
+	s.average += s.alpha * (float64(peak) - s.average)
+}
+
+// Estimate satisfies the SizeEstimator interface.
+func (s *ewmaSize) Estimate() int {
+	return int(math.Ceil(s.average))
+}
+
+// SizingPercentile sizes arenas by the p-th percentile, between 0 and 1, of
+// the last window peaks. E.g. SizingPercentile(0.99, 100) picks a size that
+// 99 of the last 100 arenas would have fit into.
+func SizingPercentile(p float64, window int) SizingStrategy {
+	if !(p > 0 && p <= 1) {
+		panic("arena: percentile must be in (0, 1]")
+	}
+	if window <= 0 {
+		panic("arena: sizing window must be positive")
+	}
+	return func() SizeEstimator {
+		return &percentileSize{p: p, window: sampleWindow{samples: make([]int, 0, window)}}
+	}
+}
+
+type percentileSize struct {
+	p      float64
+	window sampleWindow
+	sorted []int // scratch space for Estimate
+	// estimate caches the result of Estimate, which is called on every
+	// Acquire, until the next Observe makes it stale.
+	estimate int
+	stale    bool
+}
+
+// Observe satisfies the SizeEstimator interface.
+func (s *percentileSize) Observe(peak int) {
+	s.window.add(peak)
+	s.stale = true
+}
+
+// Estimate satisfies the SizeEstimator interface.
+func (s *percentileSize) Estimate() int {
+	if !s.stale {
+		return s.estimate
+	}
+	s.sorted = append(s.sorted[:0], s.window.samples...)
+	slices.Sort(s.sorted)
+	// Nearest-rank method.
+	rank := int(math.Ceil(s.p * float64(len(s.sorted))))
+	s.estimate, s.stale = s.sorted[max(rank-1, 0)], false
+	return s.estimate
+}
+
+// SizingMaxOfWindow sizes arenas by the largest of the last window peaks.
+func SizingMaxOfWindow(window int) SizingStrategy {
+	if window <= 0 {
+		panic("arena: sizing window must be positive")
+	}
+	return func() SizeEstimator {
+		return &maxSize{window: sampleWindow{samples: make([]int, 0, window)}}
+	}
+}
+
+type maxSize struct {
+	window sampleWindow
+}
+
+// Observe satisfies the SizeEstimator interface.
+func (s *maxSize) Observe(peak int) {
+	s.window.add(peak)
+}
+
+// Estimate satisfies the SizeEstimator interface.
+func (s *maxSize) Estimate() int {
+	return slices.Max(s.window.samples)
+}
+
+// sampleWindow is a ring buffer holding the last cap(samples) samples.
+type sampleWindow struct {
+	samples []int
+	next    int
+}
+
+func (w *sampleWindow) add(v int) {
+	if len(w.samples) < cap(w.samples) {
+		w.samples = append(w.samples, v)
+		return
+	}
+	w.samples[w.next] = v
+	w.next = (w.next + 1) % len(w.samples)
+}
@@ -0,0 +1,134 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package arena
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func observeAll(s SizeEstimator, peaks ...int) {
+	for _, peak := range peaks {
+		s.Observe(peak)
+	}
+}
+
+func TestSizingMean(t *testing.T) {
+	s := SizingMean()()
+	observeAll(s, 100, 200, 600)
+	assert.Equal(t, 300, s.Estimate())
+}
+
+func TestSizingEWMA(t *testing.T) {
+	s := SizingEWMA(0.5)()
+	s.Observe(100)
+	assert.Equal(t, 100, s.Estimate())
+	s.Observe(200)
+	assert.Equal(t, 150, s.Estimate())
+	s.Observe(200)
+	assert.Equal(t, 175, s.Estimate())
+
+	require.Panics(t, func() { SizingEWMA(0) })
+	require.Panics(t, func() { SizingEWMA(1.5) })
+}
+
+func TestSizingPercentile(t *testing.T) {
+	s := SizingPercentile(0.9, 10)()
+	s.Observe(500)
+	assert.Equal(t, 500, s.Estimate())
+
+	// 1..10 replace 500, which falls out of the window.
+	for i := 10; i >= 1; i-- {
+		s.Observe(i * 100)
+	}
+	assert.Equal(t, 900, s.Estimate())
+
+	// A single outlier, replacing 1000, doesn't move the estimate.
+	s.Observe(1 << 30)
+	assert.Equal(t, 900, s.Estimate())
+
+	s = SizingPercentile(1, 10)()
+	observeAll(s, 3, 1, 2)
+	assert.Equal(t, 3, s.Estimate())
+
+	require.Panics(t, func() { SizingPercentile(0, 10) })
+	require.Panics(t, func() { SizingPercentile(0.5, 0) })
+}
+
+func TestSizingPercentileCachesEstimate(t *testing.T) {
+	s := SizingPercentile(0.5, 10)()
+	observeAll(s, 100, 300, 200)
+	assert.Equal(t, 200, s.Estimate())
+
+	// Estimate doesn't sort the window again until the next Observe.
+	p := s.(*percentileSize)
+	p.window.samples[0] = 1000
+	assert.Equal(t, 200, s.Estimate())
+	s.Observe(400)
+	assert.Equal(t, 300, s.Estimate())
+}
+
+func TestSizingMaxOfWindow(t *testing.T) {
+	s := SizingMaxOfWindow(3)()
+	observeAll(s, 900, 100, 200)
+	assert.Equal(t, 900, s.Estimate())
+	s.Observe(300)
+	assert.Equal(t, 300, s.Estimate())
+
+	require.Panics(t, func() { SizingMaxOfWindow(0) })
+}
+
+func TestArenaPool_SizingStrategy(t *testing.T) {
+	pool := NewArenaPool(WithSizingStrategy(SizingMaxOfWindow(8)))
+	for _, n := range []uintptr{100, 5000, 200} {
+		item := pool.Acquire(1)
+		item.Arena.Alloc(n, 1)
+		pool.Release(item)
+	}
+	assert.Equal(t, 5000, pool.getArenaSize(1))
+	assert.IsType(t, &maxSize{}, pool.sizes[1].SizeEstimator)
+
+	// The default keeps a single outlier from sizing all arenas.
+	pool = NewArenaPool()
+	for i := range 20 {
+		item := pool.Acquire(1)
+		item.Arena.Alloc(100+uintptr(i), 1)
+		pool.Release(item)
+	}
+	item := pool.Acquire(1)
+	item.Arena.Alloc(1<<20, 1)
+	pool.Release(item)
+	assert.Equal(t, 118, pool.getArenaSize(1))
+}
+
+func TestArenaPool_MaxSizeKeys(t *testing.T) {
+	pool := NewArenaPool(WithMaxSizeKeys(2))
+	release := func(key uint64, n uintptr) {
+		item := pool.Acquire(key)
+		item.Arena.Alloc(n, 1)
+		pool.Release(item)
+	}
+
+	release(1, 100)
+	release(2, 200)
+	// Looking up key 1 makes key 2 the least recently used one.
+	assert.Equal(t, 100, pool.getArenaSize(1))
+	release(3, 300)
+
+	require.Len(t, pool.sizes, 2)
+	assert.Equal(t, 100, pool.getArenaSize(1))
+	assert.Equal(t, defaultArenaSize, pool.getArenaSize(2))
+	assert.Equal(t, 300, pool.getArenaSize(3))
+
+	stats := pool.Stats()
+	assert.Equal(t, uint64(1), stats.SizeKeyEvictions)
+	assert.Equal(t, map[uint64]int{1: 100, 3: 300}, stats.Sizes)
+
+	// An evicted key starts over.
+	release(2, 400)
+	assert.Equal(t, 400, pool.getArenaSize(2))
+	assert.Equal(t, defaultArenaSize, pool.getArenaSize(1))
+	assert.Equal(t, uint64(2), pool.Stats().SizeKeyEvictions)
+}
@@ -0,0 +1,161 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package arena
+
+import (
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"io"
+)
+
+// PoolStats is a snapshot of a Pool's counters, as returned by Pool.Stats.
+type PoolStats struct {
+	// Acquires is the number of calls to Acquire.
+	Acquires uint64 `json:"acquires"`
+	// Hits is the number of acquires served by a pooled arena.
+	Hits uint64 `json:"hits"`
+	// Misses is the number of acquires that created a new arena.
+	Misses uint64 `json:"misses"`
+	// Steals is the number of hits that a ShardedPool served from another
+	// shard than the key's.
+	Steals uint64 `json:"steals"`
+	// Collected is the number of pooled arenas found collected by the GC.
+	Collected uint64 `json:"collected"`
+	// Evictions is the number of arenas dropped because the pool exceeded
+	// its limits.
+	Evictions uint64 `json:"evictions"`
+	// SizeKeyEvictions is the number of keys whose size estimate was
+	// dropped because the size table was full.
+	SizeKeyEvictions uint64 `json:"size_key_evictions"`
+	// IdleEvictions is the number of arenas released because they were idle
+	// for longer than the idle timeout, see WithIdleTimeout.
+	IdleEvictions uint64 `json:"idle_evictions"`
+	// Trims is the number of released arenas that were trimmed.
+	Trims uint64 `json:"trims"`
+	// Prewarmed is the number of arenas added by Prewarm and PrewarmKey.
+	Prewarmed uint64 `json:"prewarmed"`
+	// Pooled is the number of arenas currently held by the pool, including
+	// arenas the GC collected that haven't been found yet.
+	Pooled int `json:"pooled"`
+	// Hot is the number of pooled arenas in the hot set, see WithHotSetSize.
+	Hot int `json:"hot"`
+	// PooledBytes is the total capacity of the pooled arenas.
+	PooledBytes int `json:"pooled_bytes"`
+	// BudgetInUse is the number of bytes charged to the memory budget for
+	// the acquired arenas, see WithMemoryBudget.
+	BudgetInUse int `json:"budget_in_use"`
+	// BudgetGrowth is the total number of bytes by which released arenas
+	// had grown beyond what was charged to the memory budget for them.
+	BudgetGrowth uint64 `json:"budget_growth"`
+	// Sizes holds the arena size the pool currently estimates per key.
+	Sizes map[uint64]int `json:"sizes"`
+}
+
+// poolCounters are the running counters reported by Pool.Stats.
+type poolCounters struct {
+	acquires         uint64
+	hits             uint64
+	misses           uint64
+	steals           uint64
+	collected        uint64
+	evictions        uint64
+	sizeKeyEvictions uint64
+	idleEvictions    uint64
+	trims            uint64
+	prewarmed        uint64
+}
+
+// Stats returns a snapshot of the pool's counters and size estimates.
+func (p *Pool) Stats() PoolStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var budgetInUse int
+	var budgetGrowth uint64
+	if p.budget != nil {
+		budgetInUse, budgetGrowth = p.budget.stats()
+	}
+	sizes := make(map[uint64]int, len(p.sizes))
+	for key, size := range p.sizes {
+		sizes[key] = size.Estimate()
+	}
+	return PoolStats{
+		Acquires:         p.stats.acquires,
+		Hits:             p.stats.hits,
+		Misses:           p.stats.misses,
+		Steals:           p.stats.steals,
+		Collected:        p.stats.collected,
+		Evictions:        p.stats.evictions,
+		SizeKeyEvictions: p.stats.sizeKeyEvictions,
+		IdleEvictions:    p.stats.idleEvictions,
+		Trims:            p.stats.trims,
+		Prewarmed:        p.stats.prewarmed,
+		Pooled:           p.count,
+		Hot:              p.hot,
+		PooledBytes:      p.bytes,
+		BudgetInUse:      budgetInUse,
+		BudgetGrowth:     budgetGrowth,
+		Sizes:            sizes,
+	}
+}
+
+// PublishExpvar publishes the pool's Stats as an expvar variable with the
+// given name, so they are served as JSON on /debug/vars.
+// Like expvar.Publish, it panics if the name is already in use.
+func (p *Pool) PublishExpvar(name string) {
+	expvar.Publish(name, expvar.Func(func() any {
+		return p.Stats()
+	}))
+}
+
+// poolStatsVersion is the version of the format written by ExportStats.
+const poolStatsVersion = 1
+
+// exportedPoolStats is the format written by ExportStats.
+type exportedPoolStats struct {
+	Version int            `json:"version"`
+	Sizes   map[uint64]int `json:"sizes"`
+}
+
+// ExportStats writes the size estimates of all keys to w, so that a new
+// process can start from them with ImportStats. The format is a JSON object
+// with the format version, currently 1, and the estimated size in bytes per
+// key, with keys as decimal strings:
+//
+//	{"version":1,"sizes":{"42":65536,"1337":2097152}}
+func (p *Pool) ExportStats(w io.Writer) error {
+	p.mu.Lock()
+	sizes := make(map[uint64]int, len(p.sizes))
+	for key, size := range p.sizes {
+		sizes[key] = size.Estimate()
+	}
+	p.mu.Unlock()
+
+	return json.NewEncoder(w).Encode(exportedPoolStats{
+		Version: poolStatsVersion,
+		Sizes:   sizes,
+	})
+}
+
+// ImportStats reads size estimates written by ExportStats from r and seeds
+// the estimates of keys the pool doesn't track yet with them. Keys the pool
+// already has observed peaks for keep their estimate.
+func (p *Pool) ImportStats(r io.Reader) error {
+	var stats exportedPoolStats
+	if err := json.NewDecoder(r).Decode(&stats); err != nil {
+		return fmt.Errorf("arena: decoding pool stats: %w", err)
+	}
+	if stats.Version != poolStatsVersion {
+		return fmt.Errorf("arena: unsupported pool stats version %d", stats.Version)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for key, size := range stats.Sizes {
+		if _, ok := p.sizes[key]; !ok {
+			p.addSizeKey(key).Observe(size)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package arena
+
+import (
+	"bytes"
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"runtime"
+	"strings"
+	"testing"
+	"weak"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestArenaPool_Stats(t *testing.T) {
+	pool := NewArenaPool(WithMaxPoolItems(1))
+	require.Equal(t, PoolStats{Sizes: map[uint64]int{}}, pool.Stats())
+
+	item1 := pool.Acquire(1)
+	item2 := pool.Acquire(2)
+	item1.Arena.Alloc(100, 1)
+	item2.Arena.Alloc(200, 1)
+	pool.Release(item1)
+	pool.Release(item2) // evicted
+
+	stats := pool.Stats()
+	assert.Equal(t, uint64(2), stats.Acquires)
+	assert.Equal(t, uint64(0), stats.Hits)
+	assert.Equal(t, uint64(2), stats.Misses)
+	assert.Equal(t, uint64(1), stats.Evictions)
+	assert.Equal(t, 1, stats.Pooled)
+	assert.Equal(t, 1024*1024, stats.PooledBytes)
+	assert.Equal(t, map[uint64]int{1: 100, 2: 200}, stats.Sizes)
+
+	item1 = pool.Acquire(1)
+	stats = pool.Stats()
+	assert.Equal(t, uint64(3), stats.Acquires)
+	assert.Equal(t, uint64(1), stats.Hits)
+	assert.Equal(t, 0, stats.Pooled)
+	assert.Equal(t, 0, stats.PooledBytes)
+
+	// Simulate the GC collecting the pooled arena.
+	pool.Release(item1)
+	pooledEntries(pool)[0].item = weak.Make(&PoolItem{Arena: NewMonotonicArena()})
+	runtime.GC()
+
+	pool.Acquire(1)
+	stats = pool.Stats()
+	assert.Equal(t, uint64(1), stats.Collected)
+	assert.Equal(t, uint64(3), stats.Misses)
+}
+
+func TestArenaPool_PublishExpvar(t *testing.T) {
+	pool := NewArenaPool()
+	pool.Release(pool.Acquire(7))
+	// expvar names are global, so make it unique for -count.
+	name := fmt.Sprintf("arena_pool_%p", pool)
+	pool.PublishExpvar(name)
+
+	var stats PoolStats
+	require.NoError(t, json.Unmarshal([]byte(expvar.Get(name).String()), &stats))
+	assert.Equal(t, pool.Stats(), stats)
+}
+
+func TestArenaPool_ExportImportStats(t *testing.T) {
+	pool := NewArenaPool()
+	items := []*PoolItem{pool.Acquire(1), pool.Acquire(1 << 63)}
+	items[0].Arena.Alloc(100, 1)
+	items[1].Arena.Alloc(200, 1)
+	pool.ReleaseMany(items)
+
+	var buf bytes.Buffer
+	require.NoError(t, pool.ExportStats(&buf))
+	assert.JSONEq(t, `{"version":1,"sizes":{"1":100,"9223372036854775808":200}}`, buf.String())
+
+	imported := NewArenaPool()
+	item := imported.Acquire(1)
+	item.Arena.Alloc(300, 1)
+	imported.Release(item)
+
+	require.NoError(t, imported.ImportStats(&buf))
+	assert.Equal(t, map[uint64]int{1: 300, 1 << 63: 200}, imported.Stats().Sizes)
+	assert.Equal(t, 200, imported.getArenaSize(1<<63))
+}
+
+func TestArenaPool_ImportStatsErrors(t *testing.T) {
+	pool := NewArenaPool()
+
+	err := pool.ImportStats(strings.NewReader(`{"version":2,"sizes":{"1":100}}`))
+	require.EqualError(t, err, "arena: unsupported pool stats version 2")
+
+	err = pool.ImportStats(strings.NewReader(`{"version":1,"sizes":{"x":100}}`))
+	require.ErrorContains(t, err, "arena: decoding pool stats")
+
+	require.Empty(t, pool.Stats().Sizes)
+}
+
+func TestArenaPool_ImportStatsRespectsMaxSizeKeys(t *testing.T) {
+	pool := NewArenaPool(WithMaxSizeKeys(2))
+
+	err := pool.ImportStats(strings.NewReader(`{"version":1,"sizes":{"1":100,"2":200,"3":300}}`))
+	require.NoError(t, err)
+	assert.Len(t, pool.Stats().Sizes, 2)
+	assert.Equal(t, uint64(1), pool.Stats().SizeKeyEvictions)
+}
@@ -2,7 +2,10 @@ package arena
 
 import (
 	"runtime"
+	"slices"
 	"testing"
+	"time"
+	"weak"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -12,7 +15,7 @@ func TestNewArenaPool(t *testing.T) {
 	pool := NewArenaPool()
 
 	require.NotNil(t, pool, "NewArenaPool returned nil")
-	assert.Len(t, pool.pool, 0, "expected empty pool")
+	assert.Equal(t, 0, pool.count, "expected empty pool")
 	assert.Len(t, pool.sizes, 0, "expected empty sizes map")
 }
 
@@ -29,7 +32,7 @@ func TestArenaPool_Acquire_EmptyPool(t *testing.T) {
 	_, err := buf.WriteString("test")
 	assert.NoError(t, err)
 
-	assert.Len(t, pool.pool, 0, "pool should still be empty")
+	assert.Equal(t, 0, pool.count, "pool should still be empty")
 }
 
 func TestArenaPool_ReleaseAndAcquire(t *testing.T) {
@@ -48,7 +51,7 @@ func TestArenaPool_ReleaseAndAcquire(t *testing.T) {
 	pool.Release(item1)
 
 	// Pool should have one item
-	assert.Len(t, pool.pool, 1, "expected pool to have 1 item")
+	assert.Equal(t, 1, pool.count, "expected pool to have 1 item")
 
 	// Acquire from pool
 	item2 := pool.Acquire(id)
@@ -56,7 +59,7 @@ func TestArenaPool_ReleaseAndAcquire(t *testing.T) {
 	require.NotNil(t, item2, "Acquire returned nil")
 
 	// Pool should be empty again
-	assert.Len(t, pool.pool, 0, "expected empty pool after acquire")
+	assert.Equal(t, 0, pool.count, "expected empty pool after acquire")
 
 	// The acquired arena should be reset and usable
 	buf2 := NewArenaBuffer(item2.Arena)
@@ -90,7 +93,7 @@ func TestArenaPool_Acquire_ProvesBugFix(t *testing.T) {
 	}
 
 	// Pool should have all items
-	assert.Len(t, pool.pool, numItems, "expected items in pool")
+	assert.Equal(t, numItems, pool.count, "expected items in pool")
 
 	// Clear every other item to simulate partial GC
 	for i := 0; i < numItems; i += 2 {
@@ -105,10 +108,10 @@ func TestArenaPool_Acquire_ProvesBugFix(t *testing.T) {
 	processed := 0
 	acquired := 0
 
-	for len(pool.pool) > 0 && processed < numItems*2 {
-		poolSizeBefore := len(pool.pool)
+	for pool.count > 0 && processed < numItems*2 {
+		poolSizeBefore := pool.count
 		item := pool.Acquire(id)
-		poolSizeAfter := len(pool.pool)
+		poolSizeAfter := pool.count
 		processed++
 
 		assert.Less(t, poolSizeAfter, poolSizeBefore, "Pool size did not decrease - item not removed properly!")
@@ -119,11 +122,11 @@ func TestArenaPool_Acquire_ProvesBugFix(t *testing.T) {
 	}
 
 	// Pool should be empty
-	assert.Len(t, pool.pool, 0, "expected empty pool")
+	assert.Equal(t, 0, pool.count, "expected empty pool")
 }
 
 func TestArenaPool_Release_PeakTracking(t *testing.T) {
-	pool := NewArenaPool()
+	pool := NewArenaPool(WithSizingStrategy(SizingMean()))
 	id := uint64(200)
 
 	// First arena
@@ -138,7 +141,7 @@ func TestArenaPool_Release_PeakTracking(t *testing.T) {
 	pool.Release(item1)
 
 	// Check that size was tracked
-	size, exists := pool.sizes[id]
+	size, exists := pool.sizes[id].SizeEstimator.(*arenaPoolItemSize)
 	require.True(t, exists, "size tracking not created")
 	assert.Equal(t, 1, size.count, "expected count 1")
 
@@ -154,6 +157,33 @@ func TestArenaPool_Release_PeakTracking(t *testing.T) {
 	assert.Equal(t, 2, size.count, "expected count 2")
 }
 
+func TestArenaPool_Release_PeakOfCycle(t *testing.T) {
+	pool := NewArenaPool()
+	id := uint64(300)
+
+	cycle := func(size uintptr) Arena {
+		item := pool.Acquire(id)
+		item.Arena.Alloc(size, 1)
+		pool.Release(item)
+		return item.Arena
+	}
+
+	reused := cycle(100 << 10)
+	for range 19 {
+		require.Same(t, reused, cycle(100<<10))
+	}
+	require.Same(t, reused, cycle(8<<20))
+	assert.Equal(t, 8<<20, reused.Peak())
+
+	// The same arena keeps being reused, but only the outlier's cycle
+	// records its lifetime peak, which falls out of the window again.
+	for range 64 {
+		require.Same(t, reused, cycle(100<<10))
+	}
+	assert.Equal(t, 100<<10, pool.getArenaSize(id))
+	assert.Equal(t, 8<<20, reused.Peak())
+}
+
 func TestArenaPool_GetArenaSize(t *testing.T) {
 	pool := NewArenaPool()
 
@@ -195,11 +225,11 @@ func TestArenaPool_MultipleItemsInPool(t *testing.T) {
 	}
 
 	// Should have all items in pool
-	assert.Len(t, pool.pool, numItems, "expected items in pool")
+	assert.Equal(t, numItems, pool.count, "expected items in pool")
 
 	// Acquire all back
 	acquired := 0
-	for len(pool.pool) > 0 {
+	for pool.count > 0 {
 		item := pool.Acquire(id)
 		if item != nil {
 			acquired++
@@ -210,7 +240,7 @@ func TestArenaPool_MultipleItemsInPool(t *testing.T) {
 }
 
 func TestArenaPool_Release_MovingWindow(t *testing.T) {
-	pool := NewArenaPool()
+	pool := NewArenaPool(WithSizingStrategy(SizingMean()))
 	id := uint64(600)
 
 	// Release exactly 50 items
@@ -223,7 +253,7 @@ func TestArenaPool_Release_MovingWindow(t *testing.T) {
 	}
 
 	// After 50 releases, verify count and total
-	size := pool.sizes[id]
+	size, _ := pool.sizes[id].SizeEstimator.(*arenaPoolItemSize)
 	require.NotNil(t, size, "size tracking should exist")
 	assert.Equal(t, 50, size.count, "expected count to be 50")
 
@@ -257,3 +287,399 @@ func TestArenaPool_Release_MovingWindow(t *testing.T) {
 	// After 10 more releases, count should be 12 (2 + 10)
 	assert.Equal(t, 12, size.count, "expected count to continue incrementing after window reset")
 }
+
+// pooledEntries returns the entries of the pool, from least to most recently
+// released.
+func pooledEntries(pool *Pool) []*poolEntry {
+	var entries []*poolEntry
+	for e := pool.oldest; e != nil; e = e.newer {
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+// pooledItems returns the live items held by the pool, from least to most
+// recently released.
+func pooledItems(pool *Pool) []*PoolItem {
+	var items []*PoolItem
+	for _, e := range pooledEntries(pool) {
+		if v := e.item.Value(); v != nil {
+			items = append(items, v)
+		}
+	}
+	return items
+}
+
+func isReleased(item *PoolItem) bool {
+	return item.Arena.(*monotonicArena).buffers[0].ptr == nil
+}
+
+func TestArenaPool_MaxItems(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy EvictionPolicy
+		kept   []int
+	}{
+		{"LIFO", EvictLIFO, []int{0, 1}},
+		{"LRU", EvictLRU, []int{1, 2}},
+		{"Largest", EvictLargest, []int{0, 2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pool := NewArenaPool(WithMaxPoolItems(2), WithEvictionPolicy(tt.policy))
+
+			items := make([]*PoolItem, 3)
+			for i := range items {
+				items[i] = pool.Acquire(1)
+				items[i].Arena.Alloc(1, 1)
+			}
+			// Make the second arena the largest one.
+			items[1].Arena.Alloc(2*1024*1024, 1)
+			for _, item := range items {
+				pool.Release(item)
+			}
+
+			require.Equal(t, 2, pool.count)
+			kept := []*PoolItem{items[tt.kept[0]], items[tt.kept[1]]}
+			assert.Equal(t, kept, pooledItems(pool))
+			for _, item := range items {
+				assert.Equal(t, !slices.Contains(kept, item), isReleased(item))
+			}
+			assert.Equal(t, kept[0].Arena.Cap()+kept[1].Arena.Cap(), pool.bytes)
+		})
+	}
+}
+
+func TestArenaPool_MaxBytes(t *testing.T) {
+	pool := NewArenaPool(WithMaxPoolBytes(3*1024*1024), WithEvictionPolicy(EvictLRU))
+
+	items := make([]*PoolItem, 4)
+	for i := range items {
+		items[i] = pool.Acquire(1)
+		items[i].Arena.Alloc(1, 1)
+	}
+	for _, item := range items[:3] {
+		pool.Release(item)
+	}
+	require.Equal(t, 3, pool.count)
+	assert.Equal(t, 3*1024*1024, pool.bytes)
+
+	pool.Release(items[3])
+	assert.Equal(t, items[1:], pooledItems(pool))
+	assert.True(t, isReleased(items[0]))
+	assert.Equal(t, 3*1024*1024, pool.bytes)
+
+	// Acquire takes the arena out of the retained bytes.
+	pool.Acquire(1)
+	assert.Equal(t, 2*1024*1024, pool.bytes)
+
+	// An arena exceeding the limit on its own isn't retained.
+	big := pool.Acquire(1)
+	big.Arena.Alloc(4*1024*1024, 1)
+	pool.Release(big)
+	assert.True(t, isReleased(big))
+	assert.Equal(t, items[1:2], pooledItems(pool))
+}
+
+func TestArenaPool_EvictionDropsCollectedFirst(t *testing.T) {
+	pool := NewArenaPool(WithMaxPoolItems(2), WithEvictionPolicy(EvictLRU))
+
+	kept := pool.Acquire(1)
+	other := pool.Acquire(1)
+	item := pool.Acquire(1)
+	item.Arena.Alloc(1, 1)
+	pool.Release(kept)
+	pool.Release(other)
+	// Drop the only reference to the second item.
+	pooledEntries(pool)[1].item = weak.Make(&PoolItem{Arena: NewMonotonicArena()})
+	runtime.GC()
+	require.Eventually(t, pool.gcRan.Load, time.Second, time.Millisecond)
+
+	pool.Release(item)
+	assert.Equal(t, []*PoolItem{kept, item}, pooledItems(pool))
+	assert.False(t, isReleased(item))
+}
+
+func TestArenaPool_EvictionSweepsOncePerGC(t *testing.T) {
+	pool := NewArenaPool(WithMaxPoolItems(1))
+	pool.gcRan.Store(false)
+
+	items := []*PoolItem{pool.Acquire(1), pool.Acquire(1), pool.Acquire(1)}
+	pool.Release(items[0])
+	// Without a GC, nothing can have been collected, so releasing at the
+	// limit evicts right away.
+	pool.Release(items[1])
+	assert.True(t, isReleased(items[1]))
+
+	runtime.GC()
+	require.Eventually(t, pool.gcRan.Load, time.Second, time.Millisecond)
+	pool.Release(items[2])
+	assert.False(t, pool.gcRan.Load())
+	assert.True(t, isReleased(items[2]))
+	assert.Equal(t, []*PoolItem{items[0]}, pooledItems(pool))
+}
+
+func TestArenaPool_ArenaFactory(t *testing.T) {
+	var hints []int
+	pool := NewArenaPool(
+		WithArenaFactory(func(sizeHint int) Arena {
+			hints = append(hints, sizeHint)
+			return NewConcurrentArena(NewMonotonicArena(WithMinBufferSize(sizeHint)))
+		}),
+		WithDefaultArenaSize(64*1024),
+		WithMinArenaSize(4096),
+		WithMaxArenaSize(128*1024),
+	)
+
+	item := pool.Acquire(1)
+	assert.IsType(t, &concurrentArena{}, item.Arena)
+	assert.Equal(t, 64*1024, item.Arena.Cap())
+
+	// A key with no peak usage gets the minimum size...
+	item.Arena.Reset()
+	pool.Release(item)
+	pool.Acquire(2) // takes the pooled arena
+	pool.Acquire(1)
+
+	// ...and a huge one the maximum.
+	big := pool.Acquire(3)
+	big.Arena.Alloc(1024*1024, 1)
+	pool.Release(big)
+	pool.Acquire(4)
+	pool.Acquire(3)
+
+	assert.Equal(t, []int{64 * 1024, 4096, 64 * 1024, 128 * 1024}, hints)
+}
+
+func TestArenaPool_DefaultMinArenaSize(t *testing.T) {
+	pool := NewArenaPool()
+
+	item := pool.Acquire(1)
+	pool.Release(item)
+	pool.Acquire(1)
+	assert.Equal(t, 0, pool.getArenaSize(1))
+
+	item = pool.Acquire(1)
+	assert.Equal(t, minBufferSize, item.Arena.Cap())
+}
+
+func TestArenaPool_AcquireBestFit(t *testing.T) {
+	pool := NewArenaPool()
+
+	// Record the expected sizes of the keys.
+	sizes := []uintptr{40 * 1024, 200 * 1024, 3 * 1024 * 1024}
+	items := make([]*PoolItem, len(sizes))
+	for i, size := range sizes {
+		items[i] = pool.Acquire(uint64(i + 1))
+		items[i].Arena.Alloc(size, 1)
+	}
+	pool.ReleaseMany(items)
+	// Take the pooled arenas out, so that the ones acquired below are
+	// created with the recorded sizes.
+	for pool.count > 0 {
+		pool.Acquire(0)
+	}
+
+	small := pool.Acquire(1)
+	medium := pool.Acquire(2)
+	large := pool.Acquire(3)
+	require.Equal(t, 40*1024, small.Arena.Cap())
+	require.Equal(t, 200*1024, medium.Arena.Cap())
+	require.Equal(t, 3*1024*1024, large.Arena.Cap())
+	pool.Release(medium)
+	pool.Release(large)
+	pool.Release(small)
+
+	assert.Same(t, large.Arena, pool.Acquire(3).Arena)
+	small2 := pool.Acquire(1)
+	assert.Same(t, small.Arena, small2.Arena)
+	// Nothing smaller than the medium arena fits key 1 any more.
+	medium2 := pool.Acquire(1)
+	assert.Same(t, medium.Arena, medium2.Arena)
+	assert.Equal(t, 0, pool.count)
+
+	// Without a fitting arena, the largest one is used.
+	pool.Release(small2)
+	pool.Release(medium2)
+	assert.Same(t, medium.Arena, pool.Acquire(3).Arena)
+	assert.Equal(t, 1, pool.count)
+	assert.Equal(t, 40*1024, pool.bytes)
+}
+
+func TestArenaPool_BestFitSkipsCollected(t *testing.T) {
+	pool := NewArenaPool()
+
+	item1 := pool.Acquire(1)
+	item2 := pool.Acquire(1)
+	pool.Release(item1)
+	pool.Release(item2)
+	pooledEntries(pool)[1].item = weak.Make(&PoolItem{Arena: NewMonotonicArena()})
+	runtime.GC()
+	require.Eventually(t, pool.gcRan.Load, time.Second, time.Millisecond)
+
+	assert.Same(t, item1.Arena, pool.Acquire(1).Arena)
+	assert.Equal(t, uint64(1), pool.Stats().Collected)
+	assert.Equal(t, 0, pool.count)
+	assert.Equal(t, 0, pool.bytes)
+	assert.Zero(t, pool.nonEmpty)
+	assert.Nil(t, pool.oldest)
+	assert.Nil(t, pool.newest)
+}
+
+func TestArenaPool_TrimOnRelease(t *testing.T) {
+	pool := NewArenaPool(
+		WithTrimFactor(4),
+		WithSizingStrategy(SizingPercentile(0.5, 8)),
+		WithDefaultArenaSize(256*1024),
+	)
+
+	// Within the factor of the 100KB estimate.
+	items := []*PoolItem{pool.Acquire(1), pool.Acquire(1)}
+	for _, item := range items {
+		item.Arena.Alloc(100*1024, 1)
+	}
+	pool.ReleaseMany(items)
+	assert.Equal(t, 256*1024, items[0].Arena.Cap())
+	assert.Equal(t, 256*1024, items[1].Arena.Cap())
+	assert.Equal(t, uint64(0), pool.Stats().Trims)
+
+	// An outlier doesn't move the median, so its arena is trimmed down to
+	// the estimate.
+	outlier := pool.Acquire(1)
+	outlier.Arena.Alloc(8*1024*1024, 1)
+	pool.Release(outlier)
+	assert.Equal(t, 100*1024, outlier.Arena.Cap())
+	assert.Equal(t, uint64(1), pool.Stats().Trims)
+	assert.Equal(t, 356*1024, pool.bytes)
+
+	// The trimmed arena goes on serving the key. Its cycles record their own
+	// peaks rather than the outlier's, so the estimate stays put and the
+	// arena isn't grown or trimmed again.
+	for range 8 {
+		item := pool.Acquire(1)
+		require.Same(t, outlier.Arena, item.Arena)
+		item.Arena.Alloc(100*1024, 1)
+		pool.Release(item)
+	}
+	assert.Equal(t, 100*1024, pool.getArenaSize(1))
+	assert.Equal(t, 100*1024, outlier.Arena.Cap())
+	assert.Equal(t, uint64(1), pool.Stats().Trims)
+	assert.Equal(t, 356*1024, pool.bytes)
+
+	// Disabled by default.
+	pool = NewArenaPool()
+	item := pool.Acquire(1)
+	item.Arena.Alloc(8*1024*1024, 1)
+	pool.Release(item)
+	item = pool.Acquire(1)
+	pool.Release(item)
+	assert.Equal(t, 9*1024*1024, item.Arena.Cap())
+}
+
+func TestArenaPool_HotSet(t *testing.T) {
+	pool := NewArenaPool(WithHotSetSize(2))
+
+	// Release four arenas without keeping references to them.
+	func() {
+		items := make([]*PoolItem, 4)
+		for i := range items {
+			items[i] = pool.Acquire(1)
+		}
+		for _, item := range items {
+			pool.Release(item)
+		}
+	}()
+	entries := pooledEntries(pool)
+	require.Len(t, entries, 4)
+	assert.Equal(t, 2, pool.Stats().Hot)
+	assert.Same(t, entries[2], pool.hotOldest)
+	for i, e := range entries {
+		assert.Equalf(t, i >= 2, e.hot, "entry %d", i)
+		assert.Equalf(t, i >= 2, e.held != nil, "entry %d", i)
+	}
+
+	runtime.GC()
+	runtime.GC()
+	assert.Len(t, pooledItems(pool), 2)
+
+	// Acquiring a hot arena makes room for the next newest live one, of
+	// which there is none.
+	item := pool.Acquire(1)
+	assert.Same(t, entries[3].held.Arena, item.Arena)
+	assert.Equal(t, 1, pool.hot)
+	assert.Same(t, entries[2], pool.hotOldest)
+
+	pool.Release(item)
+	assert.Equal(t, 2, pool.hot)
+
+	// With a live arena outside the hot set, it is promoted.
+	pool = NewArenaPool(WithHotSetSize(2))
+	items := []*PoolItem{pool.Acquire(1), pool.Acquire(1), pool.Acquire(1)}
+	pool.ReleaseMany(items)
+	assert.False(t, pooledEntries(pool)[0].hot)
+	assert.Same(t, items[2].Arena, pool.Acquire(1).Arena)
+	assert.Equal(t, 2, pool.hot)
+	assert.Same(t, items[0], pool.hotOldest.held)
+}
+
+func TestArenaPool_HotSetEviction(t *testing.T) {
+	pool := NewArenaPool(WithHotSetSize(2), WithMaxPoolItems(2), WithEvictionPolicy(EvictLRU))
+
+	items := []*PoolItem{pool.Acquire(1), pool.Acquire(1), pool.Acquire(1)}
+	for _, item := range items {
+		pool.Release(item)
+	}
+	assert.Equal(t, 2, pool.hot)
+	assert.Equal(t, items[1:], pooledItems(pool))
+	for _, e := range pooledEntries(pool) {
+		assert.True(t, e.hot)
+	}
+}
+
+// benchmarkPoolParallel acquires and releases arenas from all goroutines at
+// once, for the keys keyOf returns for a goroutine's n-th request.
+func benchmarkPoolParallel(b *testing.B, keyOf func(n uint64) uint64, acquire func(key uint64) *PoolItem, release func(*PoolItem)) {
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		var n uint64
+		for pb.Next() {
+			n++
+			item := acquire(keyOf(n))
+			item.Arena.Alloc(64, 8)
+			release(item)
+		}
+	})
+}
+
+// spreadKeys spreads requests over a few distinct operations.
+func spreadKeys(n uint64) uint64 {
+	return n % 64
+}
+
+// hotKey sends 15 of 16 requests to the same operation.
+func hotKey(n uint64) uint64 {
+	if n%16 != 0 {
+		return 0
+	}
+	return n % 64
+}
+
+func BenchmarkPoolAcquireReleaseParallel(b *testing.B) {
+	pool := NewArenaPool(WithHotSetSize(1024))
+	benchmarkPoolParallel(b, spreadKeys, pool.Acquire, pool.Release)
+}
+
+func BenchmarkShardedPoolAcquireReleaseParallel(b *testing.B) {
+	pool := NewShardedArenaPool(0, WithHotSetSize(1024))
+	benchmarkPoolParallel(b, spreadKeys, pool.Acquire, pool.Release)
+}
+
+func BenchmarkPoolAcquireReleaseParallelHotKey(b *testing.B) {
+	pool := NewArenaPool(WithHotSetSize(1024))
+	benchmarkPoolParallel(b, hotKey, pool.Acquire, pool.Release)
+}
+
+func BenchmarkShardedPoolAcquireReleaseParallelHotKey(b *testing.B) {
+	pool := NewShardedArenaPool(0, WithHotSetSize(1024))
+	benchmarkPoolParallel(b, hotKey, pool.Acquire, pool.Release)
+}
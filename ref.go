@@ -0,0 +1,49 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package arena
+
+// generational is implemented by arenas that count how often they have been
+// reset or released.
+type generational interface {
+	generation() uint64
+}
+
+// Ref is a checked handle to a value allocated from an arena.
+// It remembers the arena's generation at creation time, and Get panics once
+// the arena has been reset or released since, turning a silent
+// use-after-Reset into a crash at the offending call site.
+// Arenas that don't track generations never invalidate a Ref.
+type Ref[T any] struct {
+	ptr *T
+	a   generational
+	gen uint64
+}
+
+// NewRef returns a checked handle to ptr, which must have been allocated from a.
+func NewRef[T any](a Arena, ptr *T) Ref[T] {
+	r := Ref[T]{ptr: ptr}
+	if g, ok := a.(generational); ok {
+		r.a = g
+		r.gen = g.generation()
+	}
+	return r
+}
+
+// AllocateRef allocates a value of type T like Allocate and returns a checked handle to it.
+func AllocateRef[T any](a Arena) Ref[T] {
+	return NewRef(a, Allocate[T](a))
+}
+
+// Valid reports whether the arena has not been reset or released since the Ref was created.
+func (r Ref[T]) Valid() bool {
+	return r.a == nil || r.a.generation() == r.gen
+}
+
+// Get returns the referenced value.
+// It panics if the arena has been reset or released since the Ref was created.
+func (r Ref[T]) Get() *T {
+	if !r.Valid() {
+		panic("arena: Ref used after arena Reset or Release")
+	}
+	return r.ptr
+}
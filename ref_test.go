@@ -0,0 +1,57 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package arena
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRefGet(t *testing.T) {
+	arena := NewMonotonicArena()
+
+	r := AllocateRef[int64](arena)
+	*r.Get() = 42
+	require.True(t, r.Valid())
+	require.Equal(t, int64(42), *r.Get())
+}
+
+func TestRefPanicsAfterReset(t *testing.T) {
+	arena := NewMonotonicArena()
+
+	r := AllocateRef[int64](arena)
+	arena.Reset()
+
+	require.False(t, r.Valid())
+	require.PanicsWithValue(t, "arena: Ref used after arena Reset or Release", func() {
+		r.Get()
+	})
+
+	// Refs created after the Reset are valid again.
+	r2 := NewRef(arena, Allocate[int64](arena))
+	require.True(t, r2.Valid())
+}
+
+func TestRefPanicsAfterRelease(t *testing.T) {
+	arena := NewConcurrentArena(NewMonotonicArena())
+
+	r := AllocateRef[pointerStruct](arena)
+	arena.Release()
+
+	require.Panics(t, func() {
+		r.Get()
+	})
+}
+
+func TestRefWithoutGenerations(t *testing.T) {
+	arena := &mockArena{}
+
+	r := AllocateRef[int64](arena)
+	arena.Reset()
+	require.True(t, r.Valid())
+	require.NotNil(t, r.Get())
+
+	var zero Ref[int64]
+	require.Nil(t, zero.Get())
+}
@@ -0,0 +1,178 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package arena
+
+import (
+	"reflect"
+	"unsafe"
+)
+
+// Memory returned by Alloc is backed by []byte buffers, which the garbage
+// collector treats as pointer-free and never scans. A heap pointer stored in
+// such memory (a string, a slice, a map, ...) does not keep its referent
+// alive. Allocate and AllocateSlice therefore route types that contain
+// pointers to typed slabs: chunks allocated as []T, so the collector knows
+// exactly where the pointers are.
+
+// PointerPolicy controls how an arena handles Allocate and AllocateSlice calls
+// for types that contain pointers.
+type PointerPolicy int
+
+const (
+	// PointerPolicyScan serves pointer-bearing types from GC-scanned memory.
+	// This is the default.
+	PointerPolicyScan PointerPolicy = iota
+	// PointerPolicyHeap allocates pointer-bearing types on the heap, leaving
+	// the arena for pointer-free data only.
+	PointerPolicyHeap
+	// PointerPolicyPanic panics on any allocation of a pointer-bearing type.
+	// It is meant for debug builds, to find code that relies on such types.
+	PointerPolicyPanic
+)
+
+const (
+	minSlabChunkSize = 1024 * 4    // 4KB
+	maxSlabChunkSize = 1024 * 1024 // 1MB
+)
+
+// scanAllocator is implemented by arenas that can serve pointer-bearing types
+// from GC-scanned memory.
+type scanAllocator interface {
+	// allocScan returns a pointer to n zeroed, contiguous values of typ, or nil
+	// if the arena can't serve the request. newSlab creates the slab for typ the
+	// first time the type is requested.
+	allocScan(typ reflect.Type, n int, newSlab func() scanSlab) unsafe.Pointer
+}
+
+// scanSlab holds the typed chunks of a single pointer-bearing type.
+type scanSlab interface {
+	// alloc returns a pointer to n zeroed, contiguous values along with the
+	// number of bytes they occupy.
+	alloc(n int) (unsafe.Pointer, uintptr)
+	// reset zeroes all handed out values so the chunks can be reused.
+	reset()
+	// size returns the total capacity of the slab in bytes.
+	size() uintptr
+	// mark returns the slab's current position.
+	mark() slabMark
+	// rewind zeroes all values handed out since mark was taken.
+	rewind(m slabMark)
+}
+
+// slabMark is a position in a slab: the number of values handed out from the
+// chunk at cursor. Chunks after cursor are unused.
+type slabMark struct {
+	cursor int
+	len    int
+}
+
+type typedSlab[T any] struct {
+	chunks [][]T // len(chunk) is the number of values handed out from it
+	cursor int   // index of the chunk the most recent alloc was served from
+}
+
+func newTypedSlab[T any]() scanSlab {
+	return &typedSlab[T]{}
+}
+
+func (s *typedSlab[T]) alloc(n int) (unsafe.Pointer, uintptr) {
+	var x T
+	elemSize := unsafe.Sizeof(x)
+	for ; s.cursor < len(s.chunks); s.cursor++ {
+		c := s.chunks[s.cursor]
+		if cap(c)-len(c) >= n {
+			s.chunks[s.cursor] = c[:len(c)+n]
+			return unsafe.Pointer(unsafe.SliceData(c[len(c):])), uintptr(n) * elemSize
+		}
+	}
+
+	// Chunks double in size so that a type allocated many times ends up in a
+	// handful of chunks, while a type allocated once doesn't pin a large one.
+	chunkSize := uintptr(minSlabChunkSize)
+	if len(s.chunks) > 0 {
+		chunkSize = min(uintptr(cap(s.chunks[len(s.chunks)-1]))*elemSize*2, maxSlabChunkSize)
+	}
+	chunkLen := max(n, int(chunkSize/elemSize), 1)
+	c := make([]T, n, chunkLen)
+	s.chunks = append(s.chunks, c)
+	s.cursor = len(s.chunks) - 1
+	return unsafe.Pointer(unsafe.SliceData(c)), uintptr(n) * elemSize
+}
+
+func (s *typedSlab[T]) reset() {
+	for i, c := range s.chunks {
+		if len(c) > 0 {
+			// clear on a typed slice goes through the write barrier, which
+			// memclr on the raw bytes would bypass.
+			clear(c)
+			s.chunks[i] = c[:0]
+		}
+	}
+	s.cursor = 0
+}
+
+func (s *typedSlab[T]) mark() slabMark {
+	m := slabMark{cursor: s.cursor}
+	if s.cursor < len(s.chunks) {
+		m.len = len(s.chunks[s.cursor])
+	}
+	return m
+}
+
+func (s *typedSlab[T]) rewind(m slabMark) {
+	for i := m.cursor; i < len(s.chunks); i++ {
+		var keep int
+		if i == m.cursor {
+			keep = m.len
+		}
+		c := s.chunks[i]
+		if len(c) > keep {
+			clear(c[keep:])
+			s.chunks[i] = c[:keep]
+		}
+	}
+	s.cursor = m.cursor
+}
+
+func (s *typedSlab[T]) size() uintptr {
+	var x T
+	var total uintptr
+	for _, c := range s.chunks {
+		total += uintptr(cap(c)) * unsafe.Sizeof(x)
+	}
+	return total
+}
+
+// allocPointers serves n values of T from a's GC-scanned memory.
+// The second result reports whether T contains pointers; if it doesn't, the
+// caller should use Alloc instead. A nil pointer with true means the arena
+// can't hold T and the caller must fall back to the heap.
+func allocPointers[T any](a Arena, n int) (*T, bool) {
+	typ := reflect.TypeFor[T]()
+	if !typeHasPointers(typ) {
+		return nil, false
+	}
+	sa, ok := a.(scanAllocator)
+	if !ok || n == 0 {
+		return nil, true
+	}
+	return (*T)(sa.allocScan(typ, n, newTypedSlab[T])), true
+}
+
+// typeHasPointers reports whether values of t contain anything the garbage
+// collector has to trace: pointers, strings, slices, maps, interfaces,
+// channels or funcs.
+//
+// Allocate and AllocateSlice call it on every allocation, so rather than
+// walking the type, it reads the length of the prefix of the type's values
+// that holds pointers from the runtime's type descriptor, which is zero for
+// pointer-free types. The descriptor starts with the size and that length
+// in all Go versions.
+func typeHasPointers(t reflect.Type) bool {
+	// The data word of a reflect.Type points to the type descriptor.
+	desc := (*struct {
+		size     uintptr
+		ptrBytes uintptr
+	})((*[2]unsafe.Pointer)(unsafe.Pointer(&t))[1])
+	return desc.ptrBytes != 0
+}
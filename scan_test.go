@@ -0,0 +1,243 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package arena
+
+import (
+	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+	"unsafe"
+
+	"github.com/stretchr/testify/require"
+)
+
+type pointerStruct struct {
+	ID    int
+	Name  string
+	Items []string
+}
+
+func TestTypeHasPointers(t *testing.T) {
+	type flat struct {
+		a int64
+		b [4]float64
+	}
+	type nested struct {
+		f flat
+		p [2]*int
+	}
+	tests := []struct {
+		typ  reflect.Type
+		want bool
+	}{
+		{reflect.TypeFor[int](), false},
+		{reflect.TypeFor[flat](), false},
+		{reflect.TypeFor[[0]*int](), false},
+		{reflect.TypeFor[struct{}](), false},
+		{reflect.TypeFor[*int](), true},
+		{reflect.TypeFor[string](), true},
+		{reflect.TypeFor[[]byte](), true},
+		{reflect.TypeFor[map[int]int](), true},
+		{reflect.TypeFor[any](), true},
+		{reflect.TypeFor[chan int](), true},
+		{reflect.TypeFor[func()](), true},
+		{reflect.TypeFor[unsafe.Pointer](), true},
+		{reflect.TypeFor[nested](), true},
+		{reflect.TypeFor[pointerStruct](), true},
+		{reflect.TypeFor[[3]struct {
+			a int64
+			p *int
+		}](), true},
+		{reflect.StructOf([]reflect.StructField{
+			{Name: "A", Type: reflect.TypeFor[int64]()},
+			{Name: "S", Type: reflect.TypeFor[string]()},
+		}), true},
+	}
+	for _, tt := range tests {
+		require.Equalf(t, tt.want, typeHasPointers(tt.typ), "type %s", tt.typ)
+		// typeHasPointers relies on the type descriptor starting with the
+		// size.
+		size := *(*uintptr)((*[2]unsafe.Pointer)(unsafe.Pointer(&tt.typ))[1])
+		require.Equalf(t, tt.typ.Size(), size, "type %s", tt.typ)
+	}
+}
+
+func TestAllocatePointerTypeUsesScannedMemory(t *testing.T) {
+	arena := NewMonotonicArena(WithInitialBufferCount(1), WithMinBufferSize(1024))
+
+	v := Allocate[pointerStruct](arena)
+	require.NotNil(t, v)
+	require.False(t, isMonotonicArenaPtr(arena, unsafe.Pointer(v)), "pointer-bearing value must not live in a byte buffer")
+	require.Equal(t, int(unsafe.Sizeof(pointerStruct{})), arena.Len())
+
+	s := AllocateSlice[string](arena, 2, 8)
+	require.Len(t, s, 2)
+	require.Equal(t, 8, cap(s))
+	require.False(t, isMonotonicArenaPtr(arena, unsafe.Pointer(unsafe.SliceData(s))))
+
+	// Pointer-free types keep using the byte buffers.
+	n := Allocate[int](arena)
+	require.True(t, isMonotonicArenaPtr(arena, unsafe.Pointer(n)))
+}
+
+func TestAllocatePointerTypeSurvivesGC(t *testing.T) {
+	arena := NewMonotonicArena()
+
+	values := make([]*pointerStruct, 0, 256)
+	for i := range 256 {
+		v := Allocate[pointerStruct](arena)
+		v.ID = i
+		// Build the strings at runtime so they live on the heap and are only
+		// reachable through the arena memory.
+		v.Name = strings.Repeat("n", 8) + strconv.Itoa(i)
+		v.Items = SliceAppend(arena, v.Items, strconv.Itoa(i), strconv.Itoa(i*2))
+		values = append(values, v)
+	}
+
+	for range 3 {
+		runtime.GC()
+	}
+	// Churn the heap so freed objects would be reused and overwritten.
+	garbage := make([][]byte, 0, 1024)
+	for range 1024 {
+		garbage = append(garbage, []byte(strings.Repeat("x", 64)))
+	}
+	runtime.KeepAlive(garbage)
+
+	for i, v := range values {
+		require.Equal(t, i, v.ID)
+		require.Equal(t, "nnnnnnnn"+strconv.Itoa(i), v.Name)
+		require.Equal(t, []string{strconv.Itoa(i), strconv.Itoa(i * 2)}, v.Items)
+	}
+}
+
+func TestAllocatePointerTypeZeroAfterReset(t *testing.T) {
+	arena := NewMonotonicArena()
+
+	v := Allocate[pointerStruct](arena)
+	v.ID = 42
+	v.Name = "name"
+	v.Items = []string{"a"}
+	capBefore := arena.Cap()
+
+	arena.Reset()
+	require.Equal(t, 0, arena.Len())
+	require.Equal(t, capBefore, arena.Cap())
+
+	v2 := Allocate[pointerStruct](arena)
+	require.Equal(t, pointerStruct{}, *v2)
+	require.Equal(t, unsafe.Pointer(v), unsafe.Pointer(v2), "slab memory should be reused after Reset")
+}
+
+func TestAllocatePointerTypeRelease(t *testing.T) {
+	arena := NewMonotonicArena(WithInitialBufferCount(1), WithMinBufferSize(1024))
+	Allocate[pointerStruct](arena)
+	require.Greater(t, arena.Cap(), 1024)
+
+	arena.Release()
+	require.Equal(t, 1024, arena.Cap())
+	require.Equal(t, 0, arena.Len())
+}
+
+func TestAllocateSlicePointerTypeGrowsChunks(t *testing.T) {
+	arena := NewMonotonicArena()
+
+	// Larger than the first chunk.
+	big := AllocateSlice[*int](arena, 0, minSlabChunkSize)
+	require.Equal(t, minSlabChunkSize, cap(big))
+	small := AllocateSlice[*int](arena, 1, 1)
+	require.Len(t, small, 1)
+	require.Equal(t, int(unsafe.Sizeof(uintptr(0)))*(minSlabChunkSize+1), arena.Len())
+}
+
+func TestAllocatePointerTypeFallsBackToHeap(t *testing.T) {
+	a := &mockArena{}
+	v := Allocate[pointerStruct](a)
+	require.NotNil(t, v)
+
+	s := AllocateSlice[string](a, 1, 2)
+	require.Len(t, s, 1)
+	require.Equal(t, 2, cap(s))
+
+	concurrent := NewConcurrentArena(nil)
+	require.NotNil(t, Allocate[pointerStruct](concurrent))
+}
+
+func TestConcurrentArenaAllocatePointerType(t *testing.T) {
+	base := NewMonotonicArena()
+	arena := NewConcurrentArena(base)
+
+	v := Allocate[pointerStruct](arena)
+	require.NotNil(t, v)
+	require.Equal(t, int(unsafe.Sizeof(pointerStruct{})), arena.Len())
+	require.False(t, isMonotonicArenaPtr(base, unsafe.Pointer(v)))
+}
+
+func TestPointerPolicyHeap(t *testing.T) {
+	arena := NewMonotonicArena(WithPointerPolicy(PointerPolicyHeap))
+
+	v := Allocate[pointerStruct](arena)
+	require.NotNil(t, v)
+	s := AllocateSlice[string](arena, 1, 4)
+	require.Len(t, s, 1)
+	require.Equal(t, 4, cap(s))
+	require.Equal(t, 0, arena.Len())
+
+	// Pointer-free types are unaffected by the policy.
+	n := Allocate[int64](arena)
+	require.True(t, isMonotonicArenaPtr(arena, unsafe.Pointer(n)))
+}
+
+func TestPointerPolicyPanic(t *testing.T) {
+	arena := NewConcurrentArena(NewMonotonicArena(WithPointerPolicy(PointerPolicyPanic)))
+
+	require.PanicsWithValue(t, "arena: allocation of pointer-bearing type arena.pointerStruct", func() {
+		Allocate[pointerStruct](arena)
+	})
+	require.PanicsWithValue(t, "arena: allocation of pointer-bearing type string", func() {
+		AllocateSlice[string](arena, 0, 1)
+	})
+	require.NotPanics(t, func() {
+		AllocateSlice[byte](arena, 0, 1)
+	})
+}
+
+// benchmarkAllocate times Allocate and AllocateSlice for pointer-free types,
+// the hot path that must not pay for the pointer check.
+func benchmarkAllocate(b *testing.B, alloc func(a Arena)) {
+	arena := NewMonotonicArena(WithInitialBufferCount(1), WithMinBufferSize(64*1024*1024))
+	for b.Loop() {
+		if arena.Len() > 60*1024*1024 {
+			b.StopTimer()
+			arena.Reset()
+			b.StartTimer()
+		}
+		alloc(arena)
+	}
+}
+
+func BenchmarkAllocateStruct(b *testing.B) {
+	benchmarkAllocate(b, func(a Arena) {
+		_ = Allocate[struct{ a, b, c int64 }](a)
+	})
+}
+
+func BenchmarkAllocateInt(b *testing.B) {
+	benchmarkAllocate(b, func(a Arena) {
+		_ = Allocate[int](a)
+	})
+}
+
+func BenchmarkAllocateSliceBytes(b *testing.B) {
+	benchmarkAllocate(b, func(a Arena) {
+		_ = AllocateSlice[byte](a, 16, 16)
+	})
+}
+
+func BenchmarkAllocatePointerStruct(b *testing.B) {
+	benchmarkAllocate(b, func(a Arena) {
+		_ = Allocate[pointerStruct](a)
+	})
+}
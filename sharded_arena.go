@@ -0,0 +1,246 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package arena
+
+import (
+	"math/bits"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"unsafe"
+)
+
+// shardedArena is a concurrency-safe arena that spreads allocations over
+// several shards, each bump-allocating from its own region. Goroutines are
+// mapped to shards by the address of their stack, so a goroutine usually keeps
+// hitting the same, uncontended shard. Regions come from a shared list that is
+// only locked when a shard runs out of space.
+type shardedArena struct {
+	shards     []arenaShard
+	shardBits  int
+	regionSize uintptr
+
+	mu      sync.Mutex         // guards regions and next
+	regions []*monotonicBuffer // all regions, reused after Reset
+	next    int                // regions[:next] have been handed to shards since the last Reset
+
+	capacity atomic.Int64
+	peak     atomic.Int64
+	gen      atomic.Uint64
+}
+
+type arenaShard struct {
+	mu  sync.Mutex
+	buf *monotonicBuffer
+	len atomic.Int64 // bytes allocated through this shard since the last Reset
+	// Pad shards to separate cache lines, so that goroutines working on
+	// neighbouring shards don't contend on the same line.
+	_ [64]byte
+}
+
+// NewShardedArena returns an arena that is safe to be accessed concurrently
+// from multiple goroutines and scales with the number of goroutines
+// allocating in parallel, unlike NewConcurrentArena, which serializes every
+// call on a single mutex.
+//
+// The arena has one shard per GOMAXPROCS, each bump-allocating from its own
+// region. WithMinBufferSize sets the size of these regions and
+// WithInitialBufferCount the number of regions created upfront. These are the
+// only options it supports: it panics if passed WithPoisonOnReset or
+// WithPointerPolicy(PointerPolicyPanic). Len, Cap and Peak aggregate over all
+// shards. Reset and Release lock every shard, so they are safe, but
+// expensive, to call concurrently with Alloc.
+//
+// Types containing pointers are allocated on the heap.
+func NewShardedArena(opts ...MonotonicArenaOption) Arena {
+	m := NewMonotonicArena(opts...).(*monotonicArena)
+	m.requireBufferOptions("NewShardedArena")
+	shardBits := bits.Len(uint(runtime.GOMAXPROCS(0) - 1))
+	a := &shardedArena{
+		shards:     make([]arenaShard, 1<<shardBits),
+		shardBits:  shardBits,
+		regionSize: m.minBufferSize,
+		regions:    m.buffers,
+	}
+	a.capacity.Store(int64(m.Cap()))
+	return a
+}
+
+// lockShard locks and returns the shard of the calling goroutine, or the
+// next free one if that one is busy.
+func (a *shardedArena) lockShard() *arenaShard {
+	var hint byte
+	// Goroutine stacks are at least 2KB apart. Fibonacci hashing spreads the
+	// stack addresses evenly over the shards.
+	h := uint64(uintptr(unsafe.Pointer(&hint))>>11) * 0x9e3779b97f4a7c15
+	i := int(h >> (64 - a.shardBits) & uint64(len(a.shards)-1))
+	for probe := range a.shards {
+		s := &a.shards[(i+probe)&(len(a.shards)-1)]
+		if s.mu.TryLock() {
+			return s
+		}
+	}
+	s := &a.shards[i]
+	s.mu.Lock()
+	return s
+}
+
+// Alloc satisfies the Arena interface.
+func (a *shardedArena) Alloc(size, alignment uintptr) unsafe.Pointer {
+	if size == 0 {
+		return nil
+	}
+	s := a.lockShard()
+	if s.buf != nil {
+		if ptr, consumed, ok := s.buf.alloc(size, alignment); ok {
+			s.len.Add(int64(consumed))
+			s.mu.Unlock()
+			return ptr
+		}
+	}
+	ptr := a.allocSlow(s, size, alignment)
+	s.mu.Unlock()
+	return ptr
+}
+
+// allocSlow serves an allocation that doesn't fit into the shard's current
+// region from a new one.
+func (a *shardedArena) allocSlow(s *arenaShard, size, alignment uintptr) unsafe.Pointer {
+	// Same margin as in monotonicArena.Alloc, so the first allocation from
+	// a fresh region always satisfies the alignment.
+	need := size
+	if alignment > 1 {
+		need += alignment - 1
+		if need < size {
+			return nil
+		}
+	}
+	if need > uintptr(maxInt) {
+		return nil
+	}
+	buf := a.region(need)
+	ptr, consumed, ok := buf.alloc(size, alignment)
+	if !ok {
+		return nil
+	}
+	s.len.Add(int64(consumed))
+	// Keep allocating from whichever region has more room left, so that a
+	// single large allocation doesn't retire a mostly empty region.
+	if s.buf == nil || buf.size-buf.offset > s.buf.size-s.buf.offset {
+		s.buf = buf
+	}
+	return ptr
+}
+
+// region hands out a region of at least size bytes that no shard is using.
+func (a *shardedArena) region(size uintptr) *monotonicBuffer {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for i := a.next; i < len(a.regions); i++ {
+		if r := a.regions[i]; r.size >= size {
+			a.regions[a.next], a.regions[i] = r, a.regions[a.next]
+			a.next++
+			return r
+		}
+	}
+	r := newMonotonicBuffer(int(max(size, a.regionSize)))
+	a.regions = append(a.regions, r)
+	a.regions[a.next], a.regions[len(a.regions)-1] = r, a.regions[a.next]
+	a.next++
+	a.capacity.Add(int64(r.size))
+	return r
+}
+
+// lockAll locks every shard and the region list.
+func (a *shardedArena) lockAll() {
+	for i := range a.shards {
+		a.shards[i].mu.Lock()
+	}
+	a.mu.Lock()
+}
+
+func (a *shardedArena) unlockAll() {
+	a.mu.Unlock()
+	for i := range a.shards {
+		a.shards[i].mu.Unlock()
+	}
+}
+
+// rewindShards detaches all regions from the shards, updating the peak with
+// the total allocated before.
+func (a *shardedArena) rewindShards() {
+	a.updatePeak()
+	for i := range a.shards {
+		a.shards[i].buf = nil
+		a.shards[i].len.Store(0)
+	}
+	a.next = 0
+	a.gen.Add(1)
+}
+
+// updatePeak records the current length as peak if it's a new maximum.
+// Between two Resets the length only grows, so sampling it whenever Peak is
+// read or the arena is reset is enough to track the exact maximum.
+func (a *shardedArena) updatePeak() int64 {
+	n := int64(a.Len())
+	for {
+		peak := a.peak.Load()
+		if n <= peak {
+			return peak
+		}
+		if a.peak.CompareAndSwap(peak, n) {
+			return n
+		}
+	}
+}
+
+// Reset satisfies the Arena interface.
+func (a *shardedArena) Reset() {
+	a.lockAll()
+	defer a.unlockAll()
+	for _, r := range a.regions[:a.next] {
+		r.reset()
+	}
+	a.rewindShards()
+}
+
+// Release satisfies the Arena interface.
+func (a *shardedArena) Release() {
+	a.lockAll()
+	defer a.unlockAll()
+	for _, r := range a.regions {
+		r.release()
+	}
+	a.rewindShards()
+}
+
+// Len returns the total number of bytes currently allocated in the arena.
+func (a *shardedArena) Len() int {
+	var total int64
+	for i := range a.shards {
+		total += a.shards[i].len.Load()
+	}
+	return int(total)
+}
+
+// Cap returns the total capacity (maximum bytes) that can be allocated in the arena.
+func (a *shardedArena) Cap() int {
+	return int(a.capacity.Load())
+}
+
+// Peak returns the peak number of bytes that have been allocated in the arena.
+// This value is not reset when Reset is called, allowing tracking of maximum usage.
+func (a *shardedArena) Peak() int {
+	return int(a.updatePeak())
+}
+
+// cyclePeak satisfies the cyclePeaker interface. The length only grows
+// between two Resets, so the current length is the peak of the cycle.
+func (a *shardedArena) cyclePeak() int {
+	return a.Len()
+}
+
+// generation satisfies the generational interface.
+func (a *shardedArena) generation() uint64 {
+	return a.gen.Load()
+}
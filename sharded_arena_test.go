@@ -0,0 +1,198 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package arena
+
+import (
+	"runtime"
+	"sync"
+	"testing"
+	"unsafe"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestShardedArenaLenCapPeak(t *testing.T) {
+	arena := NewShardedArena(WithInitialBufferCount(2), WithMinBufferSize(1024))
+	require.Equal(t, 2048, arena.Cap())
+	require.Equal(t, 0, arena.Len())
+	require.Nil(t, arena.Alloc(0, 1))
+
+	arena.Alloc(100, 1)
+	arena.Alloc(200, 1)
+	require.Equal(t, 300, arena.Len())
+	require.Equal(t, 2048, arena.Cap())
+	require.Equal(t, 300, arena.Peak())
+
+	// Larger than a region.
+	arena.Alloc(4096, 1)
+	require.Equal(t, 4396, arena.Len())
+	require.Equal(t, 2048+4096, arena.Cap())
+
+	arena.Reset()
+	require.Equal(t, 0, arena.Len())
+	require.Equal(t, 2048+4096, arena.Cap())
+	require.Equal(t, 4396, arena.Peak())
+
+	arena.Alloc(10, 1)
+	require.Equal(t, 4396, arena.Peak())
+
+	arena.Release()
+	require.Equal(t, 0, arena.Len())
+	require.Equal(t, 2048+4096, arena.Cap())
+}
+
+func TestShardedArenaRejectsUnsupportedOptions(t *testing.T) {
+	require.PanicsWithValue(t, "arena: NewShardedArena doesn't support WithPoisonOnReset", func() {
+		NewShardedArena(WithPoisonOnReset())
+	})
+	require.PanicsWithValue(t, "arena: NewShardedArena doesn't support PointerPolicyPanic", func() {
+		NewShardedArena(WithPointerPolicy(PointerPolicyPanic))
+	})
+	require.NotPanics(t, func() {
+		NewShardedArena(WithPointerPolicy(PointerPolicyHeap), WithMinBufferSize(1024))
+	})
+}
+
+func TestShardedArenaAlignmentAndZeroing(t *testing.T) {
+	arena := NewShardedArena(WithMinBufferSize(256))
+
+	ptr := arena.Alloc(100, 64)
+	require.Zero(t, uintptr(ptr)%64)
+	s := unsafe.Slice((*byte)(ptr), 100)
+	for i := range s {
+		s[i] = 0xff
+	}
+
+	arena.Reset()
+	ptr2 := arena.Alloc(100, 64)
+	require.Equal(t, make([]byte, 100), unsafe.Slice((*byte)(ptr2), 100))
+}
+
+func TestShardedArenaReusesRegionsAfterReset(t *testing.T) {
+	arena := NewShardedArena(WithInitialBufferCount(0), WithMinBufferSize(1024))
+
+	for range 10 {
+		var wg sync.WaitGroup
+		for range 8 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for range 100 {
+					arena.Alloc(64, 8)
+				}
+			}()
+		}
+		wg.Wait()
+		require.Equal(t, 8*100*64, arena.Len())
+		arena.Reset()
+	}
+	// Regions are recycled: capacity stays bounded by a single round's worth
+	// plus one partially used region per shard.
+	require.LessOrEqual(t, arena.Cap(), 8*100*64+runtime.GOMAXPROCS(0)*2*1024)
+	require.Equal(t, 8*100*64, arena.Peak())
+}
+
+func TestShardedArenaConcurrentAllocationsDontOverlap(t *testing.T) {
+	arena := NewShardedArena(WithMinBufferSize(4096))
+
+	const goroutines, allocs = 16, 500
+	var wg sync.WaitGroup
+	results := make([][]*int64, goroutines)
+	for g := range goroutines {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range allocs {
+				v := Allocate[int64](arena)
+				*v = int64(g*allocs + i)
+				results[g] = append(results[g], v)
+			}
+		}()
+	}
+	wg.Wait()
+
+	require.Equal(t, goroutines*allocs*8, arena.Len())
+	for g, vs := range results {
+		for i, v := range vs {
+			require.Equal(t, int64(g*allocs+i), *v)
+		}
+	}
+}
+
+func TestShardedArenaConcurrentResetAndStats(t *testing.T) {
+	arena := NewShardedArena(WithMinBufferSize(1024))
+
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 1000 {
+				arena.Alloc(16, 8)
+				_ = arena.Len()
+				_ = arena.Peak()
+			}
+		}()
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for range 10 {
+			arena.Reset()
+		}
+	}()
+	wg.Wait()
+	require.LessOrEqual(t, arena.Len(), arena.Peak())
+}
+
+func TestShardedArenaRef(t *testing.T) {
+	arena := NewShardedArena()
+
+	r := AllocateRef[int64](arena)
+	require.True(t, r.Valid())
+	arena.Reset()
+	require.False(t, r.Valid())
+
+	// Types containing pointers go to the heap.
+	p := Allocate[pointerStruct](arena)
+	require.NotNil(t, p)
+	require.Equal(t, 0, arena.Len())
+}
+
+func BenchmarkShardedArenaAlloc(b *testing.B) {
+	arena := NewShardedArena(WithInitialBufferCount(1), WithMinBufferSize(1024*1024))
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ptr := arena.Alloc(100, 1)
+		_ = ptr
+	}
+}
+
+func BenchmarkShardedArenaAllocParallel(b *testing.B) {
+	benchmarkParallelAlloc(b, NewShardedArena(WithMinBufferSize(1024*1024)))
+}
+
+// benchmarkParallelAlloc spreads b.N allocations over GOMAXPROCS goroutines.
+// The arena is reset after every batch to keep memory bounded.
+func benchmarkParallelAlloc(b *testing.B, arena Arena) {
+	const batch = 1 << 16
+	workers := runtime.GOMAXPROCS(0)
+
+	b.ResetTimer()
+	for done := 0; done < b.N; done += batch {
+		n := min(batch, b.N-done)
+		var wg sync.WaitGroup
+		for w := range workers {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := w; i < n; i += workers {
+					arena.Alloc(100, 8)
+				}
+			}()
+		}
+		wg.Wait()
+		arena.Reset()
+	}
+}
@@ -0,0 +1,180 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package arena
+
+import (
+	"context"
+	"maps"
+	"math/bits"
+	"runtime"
+	"unsafe"
+)
+
+// ShardedPool is a Pool split into shards with a mutex each, so that
+// goroutines acquiring and releasing arenas in parallel don't contend on a
+// single lock. Arenas are pooled in the shard of the calling goroutine, so
+// that a single hot key is spread over all shards. The size estimates are
+// tracked apart from that: keys are mapped to shards by their hash, and each
+// key's estimate lives in its shard, like in a Pool. When the goroutine's
+// shard has no pooled arena, Acquire steals one from another shard before
+// creating a new arena.
+type ShardedPool struct {
+	shards    []*Pool
+	shardBits int
+}
+
+// NewShardedArenaPool creates a ShardedPool with the given number of shards,
+// rounded up to a power of two, or one per GOMAXPROCS if shards is zero.
+// The options configure each shard, so limits like WithMaxPoolItems apply
+// per shard.
+func NewShardedArenaPool(shards int, opts ...PoolOption) *ShardedPool {
+	if shards <= 0 {
+		shards = runtime.GOMAXPROCS(0)
+	}
+	shardBits := bits.Len(uint(shards - 1))
+	s := &ShardedPool{
+		shards:    make([]*Pool, 1<<shardBits),
+		shardBits: shardBits,
+	}
+	for i := range s.shards {
+		s.shards[i] = NewArenaPool(opts...)
+	}
+	return s
+}
+
+// shard returns the index of the shard tracking the size estimate of key.
+func (s *ShardedPool) shard(key uint64) int {
+	if s.shardBits == 0 {
+		return 0
+	}
+	// Fibonacci hashing, so that sequential keys are spread evenly.
+	return int((key * 0x9e3779b97f4a7c15) >> (64 - s.shardBits))
+}
+
+// localShard returns the index of the shard pooling the arenas of the
+// calling goroutine.
+func (s *ShardedPool) localShard() int {
+	if s.shardBits == 0 {
+		return 0
+	}
+	var hint byte
+	// Like shardedArena.lockShard, hash the goroutine's stack address, which
+	// is at least 2KB apart from those of other goroutines.
+	h := uint64(uintptr(unsafe.Pointer(&hint))>>11) * 0x9e3779b97f4a7c15
+	return int(h >> (64 - s.shardBits))
+}
+
+// sizeHint returns the size new arenas for key are created with.
+func (s *ShardedPool) sizeHint(key uint64) int {
+	p := s.shards[s.shard(key)]
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.sizeHint(key)
+}
+
+// recordPeak records the peak usage of an arena released for key and
+// returns the key's new size hint.
+func (s *ShardedPool) recordPeak(key uint64, peak int) int {
+	p := s.shards[s.shard(key)]
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.recordPeak(key, peak)
+	return p.sizeHint(key)
+}
+
+// Acquire is like Pool.Acquire.
+func (s *ShardedPool) Acquire(key uint64) *PoolItem {
+	item, _ := s.acquire(nil, s.localShard(), key)
+	return item
+}
+
+// AcquireContext is like Pool.AcquireContext.
+func (s *ShardedPool) AcquireContext(ctx context.Context, key uint64) (*PoolItem, error) {
+	return s.acquire(ctx, s.localShard(), key)
+}
+
+// acquire acquires an arena from shard i. The key's size hint is looked up
+// before locking the shard, so that no two shards are locked at once.
+func (s *ShardedPool) acquire(ctx context.Context, i int, key uint64) (*PoolItem, error) {
+	sizeHint := s.sizeHint(key)
+	p := s.shards[i]
+	p.mu.Lock()
+	return p.acquireSized(ctx, key, sizeHint, func(sizeHint int) *PoolItem {
+		// The shard's mutex is held, so only try the others' to avoid
+		// deadlocks, skipping the busy ones.
+		for j := 1; j < len(s.shards); j++ {
+			victim := s.shards[(i+j)&(len(s.shards)-1)]
+			if !victim.mu.TryLock() {
+				continue
+			}
+			v := victim.take(sizeHint)
+			victim.mu.Unlock()
+			if v != nil {
+				return v
+			}
+		}
+		return nil
+	})
+}
+
+// Release is like Pool.Release. The arena is pooled in the shard of the
+// calling goroutine, while its peak is recorded by the shard of its key.
+func (s *ShardedPool) Release(item *PoolItem) {
+	s.release(s.localShard(), item)
+}
+
+// ReleaseMany is like Pool.ReleaseMany.
+func (s *ShardedPool) ReleaseMany(items []*PoolItem) {
+	i := s.localShard()
+	for _, item := range items {
+		s.release(i, item)
+	}
+}
+
+// release releases an item to shard i.
+func (s *ShardedPool) release(i int, item *PoolItem) {
+	p := s.shards[i]
+	peak := p.reset(item)
+	size := s.recordPeak(item.Key, peak)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.releaseIdle()
+	p.trim(item, size)
+	p.put(item, false)
+}
+
+// Stats returns the sum of the shards' Stats.
+// BudgetInUse and BudgetGrowth are those of the budget the shards share.
+func (s *ShardedPool) Stats() PoolStats {
+	total := PoolStats{Sizes: make(map[uint64]int)}
+	for _, shard := range s.shards {
+		stats := shard.Stats()
+		total.Acquires += stats.Acquires
+		total.Hits += stats.Hits
+		total.Misses += stats.Misses
+		total.Steals += stats.Steals
+		total.Collected += stats.Collected
+		total.Evictions += stats.Evictions
+		total.SizeKeyEvictions += stats.SizeKeyEvictions
+		total.IdleEvictions += stats.IdleEvictions
+		total.Trims += stats.Trims
+		total.Prewarmed += stats.Prewarmed
+		total.Pooled += stats.Pooled
+		total.Hot += stats.Hot
+		total.PooledBytes += stats.PooledBytes
+		// The shards share the budget, so don't add it up.
+		total.BudgetInUse = stats.BudgetInUse
+		total.BudgetGrowth = stats.BudgetGrowth
+		maps.Copy(total.Sizes, stats.Sizes)
+	}
+	return total
+}
+
+// Close is like Pool.Close, stopping the goroutines of all shards.
+func (s *ShardedPool) Close() {
+	for _, shard := range s.shards {
+		shard.Close()
+	}
+}
@@ -0,0 +1,128 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package arena
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewShardedArenaPool(t *testing.T) {
+	assert.Len(t, NewShardedArenaPool(1).shards, 1)
+	assert.Len(t, NewShardedArenaPool(3).shards, 4)
+	assert.Len(t, NewShardedArenaPool(8).shards, 8)
+
+	pool := NewShardedArenaPool(4, WithMaxPoolItems(1))
+	for _, shard := range pool.shards {
+		assert.Equal(t, 1, shard.maxItems)
+	}
+
+	seen := make(map[int]bool)
+	for key := range uint64(64) {
+		i := pool.shard(key)
+		require.Less(t, i, 4)
+		seen[i] = true
+	}
+	assert.Len(t, seen, 4)
+}
+
+func TestShardedPool_SizeTracking(t *testing.T) {
+	pool := NewShardedArenaPool(4)
+
+	for key := range uint64(8) {
+		item := pool.Acquire(key)
+		item.Arena.Alloc(uintptr(key+1)*1000, 1)
+		pool.Release(item)
+	}
+
+	stats := pool.Stats()
+	for key := range uint64(8) {
+		assert.Equal(t, int(key+1)*1000, stats.Sizes[key])
+		assert.Equal(t, int(key+1)*1000, pool.shards[pool.shard(key)].getArenaSize(key))
+	}
+	// All keys share the arena created for the first one, but each records
+	// only its own peak.
+	assert.Equal(t, uint64(8), stats.Acquires)
+	assert.Equal(t, uint64(7), stats.Hits)
+	assert.Equal(t, uint64(1), stats.Misses)
+}
+
+func TestShardedPool_WorkStealing(t *testing.T) {
+	pool := NewShardedArenaPool(4)
+
+	item, err := pool.acquire(nil, 0, 1)
+	require.NoError(t, err)
+	pool.release(0, item)
+	require.Equal(t, 1, pool.shards[0].count)
+
+	stolen, err := pool.acquire(nil, 1, 2)
+	require.NoError(t, err)
+	require.Same(t, item.Arena, stolen.Arena)
+	assert.Equal(t, uint64(2), stolen.Key)
+
+	stats := pool.Stats()
+	assert.Equal(t, uint64(1), stats.Steals)
+	assert.Equal(t, uint64(1), stats.Hits)
+	assert.Equal(t, 0, stats.Pooled)
+
+	// Released to the shard it's released from.
+	pool.release(1, stolen)
+	assert.Equal(t, 1, pool.shards[1].count)
+}
+
+func TestShardedPool_HotKey(t *testing.T) {
+	pool := NewShardedArenaPool(4)
+	const key = 42
+
+	// Goroutines on different shards acquire and release the same key
+	// without sharing a shard, while its size is tracked by one of them.
+	items := make([]*PoolItem, len(pool.shards))
+	for i := range pool.shards {
+		var err error
+		items[i], err = pool.acquire(nil, i, key)
+		require.NoError(t, err)
+		items[i].Arena.Alloc(uintptr(i+1)*1000, 1)
+	}
+	for i, item := range items {
+		pool.release(i, item)
+	}
+	for i, shard := range pool.shards {
+		assert.Equalf(t, 1, shard.count, "shard %d", i)
+		if i == pool.shard(key) {
+			assert.Equal(t, 4000, shard.getArenaSize(key))
+		} else {
+			assert.Emptyf(t, shard.sizes, "shard %d", i)
+		}
+	}
+
+	stats := pool.Stats()
+	assert.Equal(t, map[uint64]int{key: 4000}, stats.Sizes)
+	assert.Equal(t, uint64(4), stats.Misses)
+	assert.Equal(t, uint64(0), stats.Steals)
+}
+
+func TestShardedPool_Concurrent(t *testing.T) {
+	pool := NewShardedArenaPool(4)
+
+	var wg sync.WaitGroup
+	for g := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range 200 {
+				item := pool.Acquire(uint64(g*200 + i%10))
+				*Allocate[int](item.Arena) = i
+				pool.Release(item)
+			}
+		}()
+	}
+	wg.Wait()
+
+	stats := pool.Stats()
+	assert.Equal(t, uint64(1600), stats.Acquires)
+	assert.Equal(t, stats.Acquires, stats.Hits+stats.Misses)
+	assert.Equal(t, int(stats.Misses-stats.Collected), stats.Pooled)
+}
@@ -3,6 +3,7 @@
 package arena
 
 import (
+	"reflect"
 	"unsafe"
 )
 
@@ -12,8 +13,16 @@ const growThreshold = 256
 // using the provided Arena for memory allocation.
 // If the arena is non-nil, it returns a slice with memory allocated from the arena.
 // Otherwise, it returns a slice using Go's built-in make function.
+// Slices of types containing pointers are served from GC-scanned memory when
+// the arena supports it and from the heap otherwise.
 func AllocateSlice[T any](a Arena, len, cap int) []T {
 	if a != nil {
+		if ptr, ok := allocPointers[T](a, cap); ok {
+			if ptr != nil {
+				return unsafe.Slice(ptr, cap)[:len]
+			}
+			return make([]T, len, cap)
+		}
 		var x T
 		bufSize := int(unsafe.Sizeof(x)) * cap
 		if ptr := (*T)(a.Alloc(uintptr(bufSize), unsafe.Alignof(x))); ptr != nil {
@@ -24,6 +33,22 @@ func AllocateSlice[T any](a Arena, len, cap int) []T {
 	return make([]T, len, cap)
 }
 
+// FreeSlice returns the memory of a slice allocated with AllocateSlice or
+// SliceAppend to the arena. s must have the same start and capacity as the
+// slice that was returned. Like Free, it is a no-op if the arena doesn't
+// implement Freer or the elements contain pointers.
+func FreeSlice[T any](a Arena, s []T) {
+	if a == nil || cap(s) == 0 {
+		return
+	}
+	f, ok := a.(Freer)
+	if !ok || typeHasPointers(reflect.TypeFor[T]()) {
+		return
+	}
+	var x T
+	f.Free(unsafe.Pointer(unsafe.SliceData(s)), unsafe.Sizeof(x)*uintptr(cap(s)), unsafe.Alignof(x))
+}
+
 // SliceAppend appends elements to a slice of type T using a provided Arena
 // for memory allocation if needed.
 func SliceAppend[T any](a Arena, s []T, data ...T) []T {
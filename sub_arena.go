@@ -0,0 +1,120 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package arena
+
+import (
+	"reflect"
+	"unsafe"
+)
+
+// subArena is a monotonic arena whose buffers are chunks allocated from a
+// parent arena. It can be reset any number of times on its own, reusing its
+// chunks, while the chunks themselves are only reclaimed when the parent is
+// reset or released.
+type subArena struct {
+	*monotonicArena
+	parent    Arena
+	parentGen uint64
+}
+
+// NewSubArena creates an arena that carves its buffers out of parent, e.g. a
+// scratch arena for a single subgraph fetch that lives inside a request arena
+// and is recycled many times per request.
+//
+// The sub-arena can be Reset independently and reports its own Len, Cap and
+// Peak. Its chunks stay allocated in the parent until the parent is reset or
+// released; Release on the sub-arena merely stops using them. When the parent
+// tracks generations, like all arenas in this package do, the sub-arena
+// notices a parent Reset and starts over with fresh chunks, which makes any
+// memory previously handed out by the sub-arena invalid as well.
+//
+// The options configure the chunks like the buffers of NewMonotonicArena,
+// except that chunks are always carved on demand, so WithInitialBufferCount
+// has no effect.
+func NewSubArena(parent Arena, opts ...MonotonicArenaOption) Arena {
+	a := &subArena{
+		monotonicArena: NewMonotonicArena(opts...).(*monotonicArena),
+		parent:         parent,
+	}
+	a.buffers = nil
+	a.grow = func(size uintptr) unsafe.Pointer {
+		return parent.Alloc(size, unsafe.Alignof(uintptr(0)))
+	}
+	if g, ok := parent.(generational); ok {
+		a.parentGen = g.generation()
+	}
+	return a
+}
+
+// sync drops all chunks if the parent has been reset or released since they
+// were allocated.
+func (a *subArena) sync() {
+	g, ok := a.parent.(generational)
+	if !ok {
+		return
+	}
+	if gen := g.generation(); gen != a.parentGen {
+		a.parentGen = gen
+		// The chunks may already be reused by the parent, so they must not
+		// be touched, let alone zeroed.
+		a.buffers = nil
+		a.monotonicArena.Reset()
+	}
+}
+
+// Alloc satisfies the Arena interface.
+func (a *subArena) Alloc(size, alignment uintptr) unsafe.Pointer {
+	a.sync()
+	return a.monotonicArena.Alloc(size, alignment)
+}
+
+// allocScan satisfies the scanAllocator interface.
+func (a *subArena) allocScan(typ reflect.Type, n int, newSlab func() scanSlab) unsafe.Pointer {
+	a.sync()
+	return a.monotonicArena.allocScan(typ, n, newSlab)
+}
+
+// Reset satisfies the Arena interface.
+// The chunks are kept for reuse by the sub-arena.
+func (a *subArena) Reset() {
+	a.sync()
+	a.monotonicArena.Reset()
+}
+
+// Release satisfies the Arena interface.
+// The chunks are not returned to the parent before it is reset itself.
+func (a *subArena) Release() {
+	a.sync()
+	a.monotonicArena.Release()
+	a.buffers = nil
+}
+
+// Rewind satisfies the Rewinder interface.
+func (a *subArena) Rewind(cp Checkpoint) {
+	a.sync()
+	a.monotonicArena.Rewind(cp)
+}
+
+// Len returns the total number of bytes currently allocated in the arena.
+func (a *subArena) Len() int {
+	a.sync()
+	return a.monotonicArena.Len()
+}
+
+// Cap returns the total capacity (maximum bytes) that can be allocated in the arena.
+func (a *subArena) Cap() int {
+	a.sync()
+	return a.monotonicArena.Cap()
+}
+
+// generation satisfies the generational interface.
+func (a *subArena) generation() uint64 {
+	a.sync()
+	return a.monotonicArena.generation()
+}
+
+// cyclePeak satisfies the cyclePeaker interface.
+func (a *subArena) cyclePeak() int {
+	a.sync()
+	return a.monotonicArena.cyclePeak()
+}
@@ -0,0 +1,144 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package arena
+
+import (
+	"testing"
+	"unsafe"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSubArenaCarvesChunksFromParent(t *testing.T) {
+	parent := NewMonotonicArena(WithMinBufferSize(4096))
+	sub := NewSubArena(parent, WithMinBufferSize(1024))
+
+	require.Equal(t, 0, sub.Cap())
+	require.Equal(t, 0, parent.Len())
+
+	ptr := sub.Alloc(100, 1)
+	require.NotNil(t, ptr)
+	require.True(t, isMonotonicArenaPtr(parent, ptr))
+	require.Equal(t, 100, sub.Len())
+	require.Equal(t, 1024, sub.Cap())
+	require.Equal(t, 1024, parent.Len())
+
+	// A request larger than the chunk size gets its own chunk.
+	sub.Alloc(2000, 1)
+	require.Equal(t, 2100, sub.Len())
+	require.Equal(t, 3024, sub.Cap())
+	require.Equal(t, 3024, parent.Len())
+	require.Equal(t, 2100, sub.Peak())
+}
+
+func TestSubArenaResetReusesChunks(t *testing.T) {
+	parent := NewMonotonicArena()
+	sub := NewSubArena(parent, WithMinBufferSize(1024))
+
+	for range 100 {
+		s := AllocateSlice[byte](sub, 512, 512)
+		for i := range s {
+			s[i] = 0xff
+		}
+		sub.Alloc(256, 8)
+		sub.Reset()
+		require.Equal(t, 0, sub.Len())
+	}
+	require.Equal(t, 1024, parent.Len(), "recycling the sub-arena must not grow the parent")
+
+	s := AllocateSlice[byte](sub, 512, 512)
+	require.Equal(t, make([]byte, 512), s)
+	require.Equal(t, 768, sub.Peak())
+}
+
+func TestSubArenaParentReset(t *testing.T) {
+	parent := NewMonotonicArena()
+	sub := NewSubArena(parent, WithMinBufferSize(1024))
+
+	ref := AllocateRef[int64](sub)
+	*ref.Get() = 42
+	require.Equal(t, 1024, sub.Cap())
+
+	parent.Reset()
+	require.False(t, ref.Valid())
+	require.Equal(t, 0, sub.Len())
+	require.Equal(t, 0, sub.Cap())
+
+	// The parent may reuse the memory right away; the sub-arena must not
+	// touch it anymore.
+	p := parent.Alloc(8, 8)
+	*(*int64)(p) = 7
+	sub.Reset()
+	require.Equal(t, int64(7), *(*int64)(p))
+
+	v := Allocate[int64](sub)
+	require.NotEqual(t, p, unsafe.Pointer(v))
+	require.Equal(t, int64(0), *v)
+	require.Equal(t, 1024+8, parent.Len())
+}
+
+func TestSubArenaRelease(t *testing.T) {
+	parent := NewMonotonicArena()
+	sub := NewSubArena(parent, WithMinBufferSize(1024))
+
+	sub.Alloc(100, 1)
+	sub.Release()
+	require.Equal(t, 0, sub.Len())
+	require.Equal(t, 0, sub.Cap())
+	require.Equal(t, 1024, parent.Len(), "chunks are only reclaimed when the parent is reset")
+
+	sub.Alloc(100, 1)
+	require.Equal(t, 2048, parent.Len())
+}
+
+func TestSubArenaNested(t *testing.T) {
+	parent := NewMonotonicArena()
+	child := NewSubArena(parent, WithMinBufferSize(4096))
+	grandchild := NewSubArena(child, WithMinBufferSize(1024))
+
+	ptr := grandchild.Alloc(8, 8)
+	require.True(t, isMonotonicArenaPtr(parent, ptr))
+	require.Equal(t, 1024, child.Len())
+	require.Equal(t, 4096, parent.Len())
+
+	child.Reset()
+	require.Equal(t, 0, grandchild.Cap())
+
+	grandchild.Alloc(8, 8)
+	parent.Reset()
+	require.Equal(t, 0, child.Cap())
+	require.Equal(t, 0, grandchild.Cap())
+}
+
+func TestSubArenaRewindAndPointerTypes(t *testing.T) {
+	parent := NewConcurrentArena(NewMonotonicArena())
+	sub := NewSubArena(parent)
+	rw := sub.(Rewinder)
+
+	v := Allocate[pointerStruct](sub)
+	v.Name = "kept"
+	cp := rw.Mark()
+	sub.Alloc(64, 1)
+	rw.Rewind(cp)
+	require.Equal(t, int(unsafe.Sizeof(pointerStruct{})), sub.Len())
+
+	cp = rw.Mark()
+	parent.Reset()
+	require.Panics(t, func() {
+		rw.Rewind(cp)
+	})
+}
+
+func TestSubArenaParentExhausted(t *testing.T) {
+	sub := NewSubArena(&nilArena{})
+	require.Nil(t, sub.Alloc(8, 1))
+	require.NotNil(t, Allocate[int](sub), "Allocate falls back to the heap")
+	require.Equal(t, 0, sub.Len())
+}
+
+// nilArena is an Arena that can't allocate anything.
+type nilArena struct{ mockArena }
+
+func (*nilArena) Alloc(_, _ uintptr) unsafe.Pointer {
+	return nil
+}